@@ -0,0 +1,595 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Returned when compiling selectors with missing parts, e.g. a nil compound selector.
+var ErrIncompleteSelector = errors.New("Incomplete selector")
+
+// Represents a compiled SelectorsGroup.
+// A Matcher matches nodes with the same semantics as MatchesSelectors, but the work
+// that only depends on the selectors is done once when compiling them. Type selector
+// names are lowercased, class selectors within a compound selector are merged into a
+// single scan of the class attribute, and each simple selector is specialized into a
+// function that is checked in order of how cheap it is, i.e. type selectors first.
+// Before running those functions, the tag, id and class required by a compound selector
+// are looked up directly, rejecting most elements upfront.
+type Matcher struct {
+	selectors SelectorsGroup   // The compiled selectors.
+	f         ElementMatchFunc // Callback used when the default matching machinery didn't find a match.
+	group     compiledGroup
+}
+
+// A compiled SelectorsGroup, holding the rightmost compound selector of each selector.
+type compiledGroup []*compiledCompound
+
+// A compiled CompoundSelector.
+type compiledCompound struct {
+	key        compoundKey       // The tag, id and class required by the compound selector.
+	match      compiledMatch     // Matches the simple selectors of the compound selector not checked by the key.
+	prev       *compiledCompound // The preceding compound selector, nil if none.
+	combinator Combinator        // The combinator used with the preceding compound selector.
+}
+
+// The tag, id and class an element must have to match a compound selector, empty if not required.
+// They're checked before the remaining simple selectors, reading the attributes of HTML nodes directly.
+// Only simple selectors never falling back to the callback function are used as keys.
+type compoundKey struct {
+	name, lower string // The local name and its lower case version, used for elements in HTML documents.
+	id          string
+	class       string
+}
+
+// A function matching one or more simple selectors against an element.
+// The matchState is only used for caching the results of :has() and may be nil.
+type compiledMatch func(*matchState, Element) bool
+
+// Compiles the given SelectorsGroup s into a Matcher.
+func Compile(s SelectorsGroup) (*Matcher, error) {
+	return CompileWithMatchFunc(s, nil)
+}
+
+// Compiles the given SelectorsGroup s into a Matcher using the callback function
+// f if the default matching machinery didn't find a match.
+func CompileWithMatchFunc(s SelectorsGroup, f SimpleSelectorMatchFunc) (*Matcher, error) {
+	return CompileWithElementMatchFunc(s, htmlMatchFunc(f))
+}
+
+// Compiles the given SelectorsGroup s into a Matcher using the callback function
+// f if the default matching machinery didn't find a match.
+func CompileWithElementMatchFunc(s SelectorsGroup, f ElementMatchFunc) (*Matcher, error) {
+	g, err := compileSelectors(s, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Matcher{selectors: s, f: f, group: g}, nil
+}
+
+// Returns the compiled selectors.
+func (m *Matcher) Selectors() SelectorsGroup {
+	return m.selectors
+}
+
+// Matches the compiled selectors against the HTML node n.
+// The root element is matched if n is a document node.
+func (m *Matcher) Matches(n *html.Node) bool {
+	e := htmlElement(n)
+	return e != nil && m.group.matches(nil, e)
+}
+
+// Matches the compiled selectors against the Element e.
+func (m *Matcher) MatchesElement(e Element) bool {
+	return m.group.matches(nil, e)
+}
+
+// Returns the first node within n, in depth-first pre-order, that matches the compiled selectors.
+// Returns nil if no node matches. The :scope pseudo class matches n, or the root element if n is a document node.
+func (m *Matcher) Query(n *html.Node) *html.Node {
+	s := &matchState{f: m.f, scope: htmlElementOrNil(n)}
+	if hasPositional(m.selectors) {
+		return firstNode(s.queryPositional(m.selectors, htmlElements(n)))
+	}
+
+	return find(n, func(x *html.Node) bool {
+		return m.group.matches(s, HTMLElement{x})
+	})
+}
+
+// Returns all the nodes within n that match the compiled selectors.
+// The :scope pseudo class matches n, or the root element if n is a document node.
+func (m *Matcher) QueryAll(n *html.Node) []*html.Node {
+	s := &matchState{f: m.f, scope: htmlElementOrNil(n)}
+	if hasPositional(m.selectors) {
+		return nodes(s.queryPositional(m.selectors, htmlElements(n)))
+	}
+
+	var result []*html.Node
+	Traverse(n, func(x *html.Node) {
+		if m.group.matches(s, HTMLElement{x}) {
+			result = append(result, x)
+		}
+	})
+
+	return result
+}
+
+// Returns all the elements within e, including e itself, that match the compiled selectors.
+// The :scope pseudo class matches e.
+func (m *Matcher) QueryAllElements(e Element) []Element {
+	s := &matchState{f: m.f, scope: e}
+	if hasPositional(m.selectors) {
+		return s.queryPositional(m.selectors, elementsWithin(e))
+	}
+
+	var result []Element
+	TraverseElements(e, func(x Element) {
+		if m.group.matches(s, x) {
+			result = append(result, x)
+		}
+	})
+
+	return result
+}
+
+func (g compiledGroup) matches(s *matchState, e Element) bool {
+	for _, c := range g {
+		if c.matches(s, e) == matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches the compound selector c and the ones preceding it against e.
+// See matchesCompoundSelector.
+func (c *compiledCompound) matches(s *matchState, e Element) matchingResult {
+	if c.key.rejects(e) || !c.match(s, e) {
+		return restartFromClosestLaterSibling
+	}
+
+	if c.prev == nil {
+		return matched
+	}
+
+	siblings := c.combinator == NextSibling || c.combinator == LaterSibling
+	candidateNotFound := notMatched
+	if siblings {
+		candidateNotFound = restartFromClosestDescendant
+	}
+
+	for {
+		if siblings {
+			e = e.PrevSibling()
+		} else {
+			e = e.Parent()
+		}
+
+		if e == nil {
+			return candidateNotFound
+		}
+
+		r := c.prev.matches(s, e)
+		if r == matched || r == notMatched {
+			return r
+		}
+
+		switch c.combinator {
+		case Child:
+			return restartFromClosestDescendant
+		case NextSibling:
+			return r
+		case LaterSibling:
+			if r == restartFromClosestDescendant {
+				return r
+			}
+		}
+	}
+}
+
+func compileSelectors(s SelectorsGroup, f ElementMatchFunc) (compiledGroup, error) {
+	g := make(compiledGroup, 0, len(s))
+	for _, x := range s {
+		if x == nil || x.CompoundSelector == nil {
+			return nil, ErrIncompleteSelector
+		}
+
+		c, err := compileCompoundSelector(x.CompoundSelector, f)
+		if err != nil {
+			return nil, err
+		}
+
+		// A selector with a pseudo element never matches an element.
+		if x.PseudoElement == nil {
+			g = append(g, c)
+		}
+	}
+
+	return g, nil
+}
+
+func compileCompoundSelector(s *CompoundSelector, f ElementMatchFunc) (*compiledCompound, error) {
+	k, rest := newCompoundKey(s.SimpleSelectors)
+	m, err := compileSimpleSelectors(rest, f)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &compiledCompound{key: k, match: m}
+	if s.Prev != nil {
+		if s.Prev.CompoundSelector == nil {
+			return nil, ErrIncompleteSelector
+		}
+
+		if c.prev, err = compileCompoundSelector(s.Prev.CompoundSelector, f); err != nil {
+			return nil, err
+		}
+
+		c.combinator = s.Prev.Combinator
+	}
+
+	return c, nil
+}
+
+// Returns the key of a compound selector having the simple selectors s, and the simple selectors
+// not fully checked by the key. The key holds the first type selector not restricted to a namespace,
+// and the first id and class selectors.
+func newCompoundKey(s []SimpleSelector) (compoundKey, []SimpleSelector) {
+	var k compoundKey
+	var rest []SimpleSelector
+	for _, x := range s {
+		switch y := x.(type) {
+		case *LocalNameSelector:
+			if k.name == "" && y.Name != "*" && (y.Namespace == nil || y.Namespace.Prefix == "*") {
+				k.name, k.lower = y.Name, strings.ToLower(y.Name)
+				continue
+			}
+		case *AttributeSelector:
+			if k.class == "" && isClassSelector(y) {
+				k.class = y.Value
+				continue
+			}
+
+			if k.id == "" && y.Match == Equals && y.Name == "id" && y.Namespace == nil && y.Case != IgnoreCase {
+				k.id = y.Value
+				continue
+			}
+		}
+
+		rest = append(rest, x)
+	}
+
+	return k, rest
+}
+
+// Returns whether e lacks the tag, id or class of the key.
+func (k *compoundKey) rejects(e Element) bool {
+	if x, ok := e.(HTMLElement); ok {
+		return k.rejectsNode(x.Node)
+	}
+
+	if k.name != "" {
+		if x := e.LocalName(); x != k.name && (!e.IsHTML() || (x != k.lower && !strings.EqualFold(x, k.name))) {
+			return true
+		}
+	}
+
+	id, class := k.id == "", k.class == ""
+	for i, c := 0, e.NumAttributes(); i < c && !(id && class); i++ {
+		if a := e.Attribute(i); a.Namespace == "" {
+			id = id || (a.Name == "id" && a.Value == k.id)
+			class = class || (a.Name == "class" && hasField(a.Value, k.class))
+		}
+	}
+
+	return !id || !class
+}
+
+// Returns whether the HTML element node n lacks the tag, id or class of the key.
+func (k *compoundKey) rejectsNode(n *html.Node) bool {
+	if k.name != "" && n.Data != k.name && n.Data != k.lower &&
+		(len(n.Data) != len(k.name) || !strings.EqualFold(n.Data, k.name)) {
+		return true
+	}
+
+	id, class := k.id == "", k.class == ""
+	for i := 0; i < len(n.Attr) && !(id && class); i++ {
+		if a := &n.Attr[i]; a.Namespace == "" {
+			id = id || (a.Key == "id" && a.Val == k.id)
+			class = class || (a.Key == "class" && hasField(a.Val, k.class))
+		}
+	}
+
+	return !id || !class
+}
+
+// Returns whether the whitespace-separated list v contains f.
+func hasField(v, f string) bool {
+	for x, i := nextField(v, 0); x != ""; x, i = nextField(v, i) {
+		if x == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Compiles the simple selectors of a compound selector into a single function,
+// ordering the checks so that the cheap and most selective ones are done first.
+func compileSimpleSelectors(s []SimpleSelector, f ElementMatchFunc) (compiledMatch, error) {
+	var names, ids, attributes, others []compiledMatch
+	var classes []string
+	for _, x := range s {
+		switch y := x.(type) {
+		case nil:
+			return nil, ErrIncompleteSelector
+		case *LocalNameSelector:
+			names = append(names, compileLocalNameSelector(y))
+		case *AttributeSelector:
+			switch {
+			case isClassSelector(y) && len(classes) < 64:
+				classes = append(classes, y.Value)
+			case y.Match == Equals && y.Name == "id":
+				ids = append(ids, compileAttributeSelector(y))
+			default:
+				attributes = append(attributes, compileAttributeSelector(y))
+			}
+		default:
+			m, err := compileSimpleSelector(x, f)
+			if err != nil {
+				return nil, err
+			}
+
+			others = append(others, m)
+		}
+	}
+
+	var r []compiledMatch
+	r = append(r, names...)
+	r = append(r, ids...)
+	if len(classes) > 0 {
+		r = append(r, compileClassSelectors(classes))
+	}
+
+	r = append(r, attributes...)
+	r = append(r, others...)
+
+	switch len(r) {
+	case 0:
+		return func(*matchState, Element) bool { return true }, nil
+	case 1:
+		return r[0], nil
+	default:
+		return func(s *matchState, e Element) bool {
+			for _, m := range r {
+				if !m(s, e) {
+					return false
+				}
+			}
+
+			return true
+		}, nil
+	}
+}
+
+// Compiles the simple selectors not handled by compileSimpleSelectors.
+func compileSimpleSelector(s SimpleSelector, f ElementMatchFunc) (compiledMatch, error) {
+	switch x := s.(type) {
+	case *PseudoNegationSelector:
+		g, err := compileSelectors(x.group(), f)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(s *matchState, e Element) bool {
+			return !g.matches(s, e)
+		}, nil
+	case *PseudoIsSelector:
+		return compileSelectorsArgument(x.Selectors, f)
+	case *PseudoWhereSelector:
+		return compileSelectorsArgument(x.Selectors, f)
+	case *PseudoHasSelector:
+		for _, r := range x.Selectors {
+			if r == nil || r.Selector == nil || r.Selector.CompoundSelector == nil {
+				return nil, ErrIncompleteSelector
+			}
+		}
+
+		// Relative selectors are matched by the default matching machinery.
+		return func(s *matchState, e Element) bool {
+			if s == nil {
+				s = &matchState{f: f}
+			}
+
+			return s.matchesPseudoHasSelector(x, e)
+		}, nil
+	case *PseudoClassSelector:
+		if x.match != nil {
+			return withMatchFunc(s, x.match, f), nil
+		}
+
+		if toASCIILower(x.Value) == "scope" {
+			return func(s *matchState, e Element) bool {
+				return s.matchesScope(e) || (f != nil && f(x, e))
+			}, nil
+		}
+
+		return withMatchFunc(s, pseudoClassMatchers[toASCIILower(x.Value)], f), nil
+	case *PseudoNthSelector:
+		isOfType, fromEnd, ok := nthSelectorKind(x.Name)
+		if !ok {
+			return withMatchFunc(s, nil, f), nil
+		}
+
+		a, b := x.A, x.B
+		if x.Of == nil {
+			return withMatchFunc(s, func(e Element) bool {
+				return matchesNthChild(e, a, b, isOfType, fromEnd, nil)
+			}, f), nil
+		}
+
+		g, err := compileSelectors(x.Of, f)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(s *matchState, e Element) bool {
+			return matchesNthChild(e, a, b, isOfType, fromEnd, func(y Element) bool {
+				return g.matches(s, y)
+			}) || (f != nil && f(x, e))
+		}, nil
+	case *PseudoFunctionSelector:
+		var m func(Element) bool
+		if x.match != nil {
+			match, data := x.match, x.Data
+			m = func(e Element) bool {
+				return match(data, e)
+			}
+		}
+
+		return withMatchFunc(s, m, f), nil
+	case *PseudoLangSelector:
+		return func(s *matchState, e Element) bool {
+			if s == nil {
+				s = &matchState{f: f}
+			}
+
+			return s.matchesPseudoLangSelector(x, e) || (f != nil && f(x, e))
+		}, nil
+	case *PseudoDirSelector:
+		return withMatchFunc(s, func(e Element) bool {
+			return matchesPseudoDirSelector(x, e)
+		}, f), nil
+	default:
+		return withMatchFunc(s, nil, f), nil
+	}
+}
+
+func compileSelectorsArgument(s SelectorsGroup, f ElementMatchFunc) (compiledMatch, error) {
+	g, err := compileSelectors(s, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.matches, nil
+}
+
+// Combines the function m used by the default matching machinery, which may be nil,
+// with the callback function f used if m didn't find a match.
+func withMatchFunc(s SimpleSelector, m func(Element) bool, f ElementMatchFunc) compiledMatch {
+	switch {
+	case m == nil && f == nil:
+		return func(*matchState, Element) bool { return false }
+	case f == nil:
+		return func(_ *matchState, e Element) bool { return m(e) }
+	case m == nil:
+		return func(_ *matchState, e Element) bool { return f(s, e) }
+	default:
+		return func(_ *matchState, e Element) bool { return m(e) || f(s, e) }
+	}
+}
+
+func compileLocalNameSelector(s *LocalNameSelector) compiledMatch {
+	name, lower := s.Name, strings.ToLower(s.Name)
+	anyNamespace := s.Namespace == nil || s.Namespace.Prefix == "*"
+	var url string
+	if !anyNamespace {
+		url = s.Namespace.URL
+	}
+
+	if name == "*" {
+		return func(_ *matchState, e Element) bool {
+			return anyNamespace || url == e.Namespace()
+		}
+	}
+
+	return func(_ *matchState, e Element) bool {
+		if x := e.LocalName(); x != name {
+			if !e.IsHTML() || (x != lower && !strings.EqualFold(x, name)) {
+				return false
+			}
+		}
+
+		return anyNamespace || url == e.Namespace()
+	}
+}
+
+func compileAttributeSelector(s *AttributeSelector) compiledMatch {
+	lower := *s
+	lower.Value = toASCIILower(s.Value)
+	lower.Case = IgnoreCase
+
+	// See ignoresAttributeCase.
+	ignoreCase := s.Case == IgnoreCase
+	defaultIgnoreCase := s.Case == DefaultCase && caseInsensitiveAttributes[s.Name]
+	return func(_ *matchState, e Element) bool {
+		for i, c := 0, e.NumAttributes(); i < c; i++ {
+			a := e.Attribute(i)
+			if a.Name != s.Name {
+				continue
+			}
+
+			if s.Namespace == nil {
+				if a.Namespace != "" {
+					continue
+				}
+			} else if s.Namespace.Prefix != "*" && s.Namespace.URL != a.Namespace {
+				continue
+			}
+
+			if ignoreCase || (defaultIgnoreCase && a.Namespace == "" && isHTMLElement(e)) {
+				if matchesAttributeValue(&lower, toASCIILower(a.Value), false) {
+					return true
+				}
+			} else if matchesAttributeValue(s, a.Value, false) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// Returns whether s is a class selector that can be merged with other class selectors.
+func isClassSelector(s *AttributeSelector) bool {
+	return s.Match == Includes && s.Name == "class" && s.Namespace == nil && s.Case != IgnoreCase &&
+		s.Value != "" && strings.IndexFunc(s.Value, IsSpace) == -1
+}
+
+// Compiles class selectors into a single scan of the class attribute.
+// There may be at most 64 classes.
+func compileClassSelectors(classes []string) compiledMatch {
+	all := ^uint64(0) >> uint(64-len(classes))
+	return func(_ *matchState, e Element) bool {
+		var found uint64
+		for i, c := 0, e.NumAttributes(); i < c; i++ {
+			a := e.Attribute(i)
+			if a.Name != "class" || a.Namespace != "" {
+				continue
+			}
+
+			for v, j := nextField(a.Value, 0); v != ""; v, j = nextField(a.Value, j) {
+				for x, c := range classes {
+					if v == c {
+						found |= 1 << uint(x)
+					}
+				}
+			}
+
+			if found == all {
+				return true
+			}
+		}
+
+		return false
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// Checks that the compiled selectors match the same nodes as the default matching machinery.
+func checkCompiledMatching(t *testing.T, s SelectorsGroup, k string, doc *html.Node, f SimpleSelectorMatchFunc) {
+	m, err := CompileWithMatchFunc(s, f)
+	if err != nil {
+		t.Errorf(`Could not compile selector %q (%s)`, k, err)
+		return
+	}
+
+	var check func(*html.Node)
+	check = func(n *html.Node) {
+		if r, want := m.Matches(n), MatchesSelectors(s, n, f); r != want {
+			t.Errorf(`Got %v matching %q against %s, want %v`, r, k, n.Data, want)
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			check(c)
+		}
+	}
+
+	check(doc)
+}
+
+func TestCompiledMatching(t *testing.T) {
+	var keys []string
+	for k := range testSelectors {
+		keys = append(keys, k)
+	}
+
+	for k, v := range testEquivalentSelectors {
+		keys = append(keys, k, v)
+	}
+
+	keys = append(keys, `h3:contains('palace')`, `.dialog.scene`, `div.a.b.c`, `:not(.dialog.scene)`, `[class~="a b"]`,
+		`DIV#scene1.scene`, `#scene1 > DIV.dialog`, `p#scene1`, `[id=scene1].scene`)
+	for _, k := range keys {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		checkCompiledMatching(t, s, k, dom, nil)
+		checkCompiledMatching(t, s, k, dom, containsMatcher)
+	}
+
+	doc, err := html.Parse(strings.NewReader(attributeCaseHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k := range testAttributeCaseSelectors {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+		} else {
+			checkCompiledMatching(t, s, k, doc, nil)
+		}
+	}
+
+	doc, err = html.Parse(strings.NewReader(nthOfHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k := range testNthOfSelectors {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+		} else {
+			checkCompiledMatching(t, s, k, doc, nil)
+		}
+	}
+}
+
+func TestCompiledNamespaceMatching(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(namespaceHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	namespaces := map[string]string{
+		"html":  XHTMLNamespace,
+		"svg":   SVGNamespace,
+		"math":  MathMLNamespace,
+		"xlink": XLinkNamespace,
+	}
+
+	for k, v := range testNamespaceSelectors {
+		s, err := ParseSelectorWithNamespaces(NewTokenizer(k), namespaces)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		checkCompiledMatching(t, s, k, doc, nil)
+		m, _ := Compile(s)
+		if r := m.QueryAll(doc); len(r) != v {
+			t.Errorf(`Got %v nodes matching %q, want %v`, len(r), k, v)
+		}
+	}
+}
+
+func TestCompileIncompleteSelector(t *testing.T) {
+	tests := []SelectorsGroup{
+		{nil},
+		{&Selector{}},
+		{&Selector{CompoundSelector: &CompoundSelector{SimpleSelectors: []SimpleSelector{nil}}}},
+		{&Selector{CompoundSelector: &CompoundSelector{Prev: &Prev{Combinator: Child}}}},
+		{&Selector{CompoundSelector: &CompoundSelector{SimpleSelectors: []SimpleSelector{NewPseudoIsSelector(SelectorsGroup{nil})}}}},
+	}
+
+	for i, s := range tests {
+		if _, err := Compile(s); err != ErrIncompleteSelector {
+			t.Errorf(`Got error %v compiling selector %d, want %v`, err, i, ErrIncompleteSelector)
+		}
+	}
+}
+
+// Returns the test selectors to benchmark, leaving out :has() whose cost is dominated
+// by caching the results of relative selectors when matching single nodes.
+func benchmarkSelectors(b *testing.B) []SelectorsGroup {
+	var r []SelectorsGroup
+	for k := range testSelectors {
+		if strings.Contains(k, ":has(") {
+			continue
+		}
+
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		r = append(r, s)
+	}
+
+	return r
+}
+
+func BenchmarkMatchesSelectors(b *testing.B) {
+	s := benchmarkSelectors(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, x := range s {
+			Traverse(dom, func(n *html.Node) {
+				MatchesSelectors(x, n, nil)
+			})
+		}
+	}
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	var m []*Matcher
+	for _, s := range benchmarkSelectors(b) {
+		x, err := Compile(s)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		m = append(m, x)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, x := range m {
+			Traverse(dom, func(n *html.Node) {
+				x.Matches(n)
+			})
+		}
+	}
+}
+
+func BenchmarkCompile(b *testing.B) {
+	s := benchmarkSelectors(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, x := range s {
+			Compile(x)
+		}
+	}
+}
+
+// Matches :contains() like containsMatcher and any nth pseudo class against h3 elements,
+// making the compiled matcher fall back to it for the nth pseudo classes themselves.
+func nthFallbackMatcher(s SimpleSelector, n *html.Node) bool {
+	if _, ok := s.(*PseudoNthSelector); ok {
+		return n.Data == "h3"
+	}
+
+	return containsMatcher(s, n)
+}
+
+func TestCompiledNthOfMatchFunc(t *testing.T) {
+	keys := []string{
+		`:nth-child(1 of :contains(palace))`,
+		`:nth-last-child(2 of :contains(a))`,
+		`h3:nth-child(100 of p)`,
+		`:nth-child(1000)`,
+		`div > :nth-child(-n+2 of :not(:contains(a)))`,
+	}
+
+	for _, k := range keys {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		checkCompiledMatching(t, s, k, dom, nthFallbackMatcher)
+	}
+}
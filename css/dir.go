@@ -0,0 +1,140 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// Returns whether the directionality of e is the one of s.
+// Only HTML elements in HTML documents have a directionality.
+func matchesPseudoDirSelector(s *PseudoDirSelector, e Element) bool {
+	if !isHTMLElement(e) {
+		return false
+	}
+
+	switch v := toASCIILower(s.Value); v {
+	case "ltr", "rtl":
+		return directionality(e) == v
+	default:
+		return false
+	}
+}
+
+// Returns the directionality of the HTML element e, either ltr or rtl.
+// See http://www.whatwg.org/specs/web-apps/current-work/multipage/dom.html#the-directionality
+func directionality(e Element) string {
+	for x := e; x != nil && isHTMLElement(x); x = x.Parent() {
+		dir, _ := attributeValue(x, "dir")
+		switch dir = toASCIILower(dir); dir {
+		case "ltr", "rtl":
+			return dir
+		case "auto":
+			return autoDirectionality(x)
+		}
+
+		switch htmlLocalName(x) {
+		case "bdi":
+			return autoDirectionality(x)
+		case "input":
+			if inputState(x) == "tel" {
+				return "ltr"
+			}
+		}
+	}
+
+	return "ltr"
+}
+
+// Returns the directionality of the HTML element e having dir=auto, given by the first character
+// of strong directionality in its value or text. Returns ltr if there's no such character.
+func autoDirectionality(e Element) string {
+	switch htmlLocalName(e) {
+	case "input":
+		switch inputState(e) {
+		case "text", "search", "tel", "url", "email":
+			v, _ := attributeValue(e, "value")
+			return textDirectionality(v, "ltr")
+		}
+
+		return "ltr"
+	case "textarea":
+		if x, ok := e.(TextContentElement); ok {
+			return textDirectionality(x.TextContent(), "ltr")
+		}
+
+		return "ltr"
+	}
+
+	if x, ok := e.(HTMLElement); ok {
+		if dir := nodeDirectionality(x.Node); dir != "" {
+			return dir
+		}
+	} else if x, ok := e.(TextContentElement); ok {
+		return textDirectionality(x.TextContent(), "ltr")
+	}
+
+	return "ltr"
+}
+
+// Returns the directionality of the first character of strong directionality in the text nodes
+// within n, skipping elements that don't affect the directionality of their ancestors.
+// Returns the empty string if there's no such character.
+func nodeDirectionality(n *html.Node) string {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.TextNode:
+			if dir := textDirectionality(c.Data, ""); dir != "" {
+				return dir
+			}
+		case html.ElementNode:
+			if skipsDirectionality(HTMLElement{c}) {
+				continue
+			}
+
+			if dir := nodeDirectionality(c); dir != "" {
+				return dir
+			}
+		}
+	}
+
+	return ""
+}
+
+// Returns whether the text within e is skipped when finding the directionality of its ancestors.
+func skipsDirectionality(e Element) bool {
+	if !isHTMLElement(e) {
+		return false
+	}
+
+	switch htmlLocalName(e) {
+	case "bdi", "script", "style", "textarea":
+		return true
+	}
+
+	dir, _ := attributeValue(e, "dir")
+	switch toASCIILower(dir) {
+	case "ltr", "rtl", "auto":
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the directionality of the first character of strong directionality in s, def if there's none.
+func textDirectionality(s string, def string) string {
+	for _, r := range s {
+		p, _ := bidi.LookupRune(r)
+		switch p.Class() {
+		case bidi.L:
+			return "ltr"
+		case bidi.R, bidi.AL:
+			return "rtl"
+		}
+	}
+
+	return def
+}
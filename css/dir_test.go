@@ -0,0 +1,55 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const dirHTML = `<!DOCTYPE html>
+<html><body id="b">
+<div id="d1" dir="RTL"><p id="p1">x</p><p id="p2" dir="ltr">x</p><input id="i1" type="tel"></div>
+<div id="d2" dir="auto"><script>שלום</script><span id="s1" dir="rtl">שלום</span> 123 שלום abc</div>
+<div id="d3" dir="auto"><bdi id="bd1">abc</bdi><b id="b1">!</b></div>
+<div id="d4" dir="auto">   </div>
+<div dir="rtl"><bdi id="bd2">abc</bdi><bdi id="bd3">مرحبا</bdi><bdi id="bd4" dir="rtl">abc</bdi><p id="p3" dir="bogus">x</p></div>
+<input id="i2" dir="auto" value="שלום"><input id="i3" dir="auto" type="checkbox" value="שלום">
+<input id="i4" dir="auto" type="foo" value="שלום">
+<textarea id="t1" dir="auto">مرحبا</textarea>
+<svg id="g1" dir="rtl"></svg>
+</body></html>`
+
+var testDirSelectors = map[string]string{
+	`:dir(rtl)`:           "d1 p1 d2 script s1 div bd3 bd4 p3 i2 i4 t1",
+	`div :dir(ltr)`:       "p2 i1 bd1 b1 bd2",
+	`:dir(LTR)#b`:         "b",
+	`:dir(auto)`:          "",
+	`div:dir(ltr)`:        "d3 d4",
+	`:not(:dir(ltr)) > p`: "p1 p2 p3",
+}
+
+func TestDirPseudoClass(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(dirHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range testDirSelectors {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		if r := nodeIDs(QueryAll(s, doc)); r != v {
+			t.Errorf(`Got %q matching %q, want %q`, r, k, v)
+		}
+
+		checkCompiledMatching(t, s, k, doc, nil)
+	}
+}
@@ -10,22 +10,51 @@ Use the high-level API to match selectors the same way you would in JavaScript:
 
 	doc := html.Parse(...)
 	nodes, err := QuerySelectorAll(`div:first-child`, doc)
+	node, err := QuerySelector(`div:first-child`, doc)
+	ok, err := Matches(`div`, node)
+	list, err := Closest(`ul, ol`, node)
 
-The low-level API can be used to gain more control of the matching process.
+The :scope pseudo class matches the node a query is relative to:
+
+	items, err := QuerySelectorAll(`:scope > li`, list)
+
+Applications can add their own pseudo classes and pseudo elements to a Registry.
+Selectors parsed using a Registry fail to parse if they use unknown pseudo classes or pseudo elements.
 Here's an example of permitting the deprecated :contains pseudo class:
 
-	doc := html.Parse(...)
-	// Create a SimpleSelectorMatchFunc
-	m := func(ss SimpleSelector, n *html.Node) bool {
-		s, ok := ss.(*PseudoFunctionSelector)
-		if !ok || s.Name != "contains" {
-			return false
+	r := NewRegistry()
+	r.RegisterPseudoFunction("contains", func(args []ComponentValue) (interface{}, error) {
+		if len(args) == 1 {
+			if tk, ok := args[0].(Token); ok && tk.Type() == String {
+				return tk.String(), nil
+			}
 		}
 
-		return strings.Contains(text(n), s.Arguments)
-	}
+		return nil, errors.New("Expected a string")
+	}, func(data interface{}, e Element) bool {
+		return strings.Contains(text(e), data.(string))
+	})
+
+	s, err := ParseSelectorWithOptions(NewTokenizer(`div:contains('foo')`), ParserOptions{Registry: r})
+	nodes := QueryAll(s, doc)
+
+The jQuery extension pseudo classes, e.g. :contains(), :eq() and :first, can be registered as well,
+making it possible to run selectors written for jQuery unchanged:
+
+	r := NewRegistry()
+	RegisterJQueryExtensions(r)
+	nodes, err := QuerySelectorAllWithOptions(`table tr:gt(0) td:first`, doc, ParserOptions{Registry: r})
 
-	s, _ := ParseSelectorFromString(`div:contains('foo')`)
+The :lang() pseudo class matches the language of elements, inherited from the closest lang or xml:lang
+attribute, using extended filtering. A Registry can set the language of documents not declaring one:
+
+	r := NewRegistry()
+	r.SetDefaultLanguage("en-US")
+	nodes, err := QuerySelectorAllWithOptions(`p:lang(en, "*-CH")`, doc, ParserOptions{Registry: r})
+
+The low-level API can be used to gain more control of the matching process, e.g. by
+matching with a SimpleSelectorMatchFunc invoked for the simple selectors the default
+matching machinery couldn't match:
 
 	var r []*html.Node
 	Traverse(doc, func(n *html.Node) {
@@ -34,6 +63,18 @@ Here's an example of permitting the deprecated :contains pseudo class:
 		}
 	})
 
+Selectors that are matched against many nodes can be compiled into a Matcher,
+which matches nodes the same way as MatchesSelectors but does so faster:
+
+	m, err := Compile(s)
+	nodes := m.QueryAll(doc)
+
+Selectors can be matched against any document tree implementing the Element interface,
+e.g. an XML document parsed with ParseXML:
+
+	doc, err := ParseXML(...)
+	elements := QueryAllElements(s, XMLRootElement(doc))
+
 The Tokenizer is a full blown CSS tokenizer and isn't limited to tokenizing what's specified in the Selector specification.
 */
 package css
@@ -0,0 +1,200 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Represents an element of a document tree that selectors are matched against.
+// The element relations only concern elements, e.g. the parent of the root element is nil.
+// Elements are used as map keys when matching some selectors, so implementations
+// must be comparable and two elements must only be equal if they're the same element.
+type Element interface {
+	LocalName() string    // The local name of the element.
+	Namespace() string    // The namespace URL of the element, empty if none.
+	IsHTML() bool         // Whether the element is in an HTML document, i.e. names are matched case-insensitively.
+	NumAttributes() int   // The number of attributes of the element.
+	Attribute(i int) Attr // Returns the i-th attribute of the element.
+	Parent() Element      // The parent element, nil if none.
+	PrevSibling() Element // The closest preceding sibling element, nil if none.
+	NextSibling() Element // The closest following sibling element, nil if none.
+	FirstChild() Element  // The first child element, nil if none.
+	LastChild() Element   // The last child element, nil if none.
+	IsRoot() bool         // Whether the element is the root of its tree, i.e. it has no parent element.
+	IsEmpty() bool        // Whether the element has no children other than whitespace, comments and processing instructions.
+}
+
+// Represents an attribute of an Element.
+type Attr struct {
+	Namespace string // The namespace URL of the attribute, empty if none.
+	Name      string // The local name of the attribute.
+	Value     string // The attribute value.
+}
+
+// Represents a callback function that will be invoked when the default
+// matching machinery couldn't match the given SimpleSelector and Element.
+// If the callback function returns true it means that the element matches.
+type ElementMatchFunc func(SimpleSelector, Element) bool
+
+// Adapts an HTML node of type html.ElementNode to the Element interface.
+type HTMLElement struct {
+	Node *html.Node
+}
+
+func (e HTMLElement) LocalName() string {
+	return e.Node.Data
+}
+
+func (e HTMLElement) Namespace() string {
+	return elementNamespace(e.Node)
+}
+
+func (e HTMLElement) IsHTML() bool {
+	return true
+}
+
+func (e HTMLElement) NumAttributes() int {
+	return len(e.Node.Attr)
+}
+
+func (e HTMLElement) Attribute(i int) Attr {
+	a := &e.Node.Attr[i]
+	return Attr{attributeNamespace(a), a.Key, a.Val}
+}
+
+func (e HTMLElement) Parent() Element {
+	return htmlElementOrNil(e.Node.Parent)
+}
+
+func (e HTMLElement) PrevSibling() Element {
+	for x := e.Node.PrevSibling; x != nil; x = x.PrevSibling {
+		if x.Type == html.ElementNode {
+			return HTMLElement{x}
+		}
+	}
+
+	return nil
+}
+
+func (e HTMLElement) NextSibling() Element {
+	for x := e.Node.NextSibling; x != nil; x = x.NextSibling {
+		if x.Type == html.ElementNode {
+			return HTMLElement{x}
+		}
+	}
+
+	return nil
+}
+
+func (e HTMLElement) FirstChild() Element {
+	for x := e.Node.FirstChild; x != nil; x = x.NextSibling {
+		if x.Type == html.ElementNode {
+			return HTMLElement{x}
+		}
+	}
+
+	return nil
+}
+
+func (e HTMLElement) LastChild() Element {
+	for x := e.Node.LastChild; x != nil; x = x.PrevSibling {
+		if x.Type == html.ElementNode {
+			return HTMLElement{x}
+		}
+	}
+
+	return nil
+}
+
+// Returns whether the element has no parent element, i.e. whether it's the root element
+// of a document, the root of a fragment or the root of a detached subtree.
+func (e HTMLElement) IsRoot() bool {
+	return htmlElementOrNil(e.Node.Parent) == nil
+}
+
+// Returns whether the element only has whitespace text and comment children.
+// See http://dev.w3.org/csswg/selectors-4/#the-empty-pseudo
+func (e HTMLElement) IsEmpty() bool {
+	for c := e.Node.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.ElementNode:
+			return false
+		case html.TextNode, html.RawNode:
+			if !isWhitespaceText(c.Data, htmlWhitespace) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Returns the concatenated data of the text nodes within the element.
+func (e HTMLElement) TextContent() string {
+	var b bytes.Buffer
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				b.WriteString(c.Data)
+			} else {
+				f(c)
+			}
+		}
+	}
+
+	f(e.Node)
+	return b.String()
+}
+
+// The document white space characters of HTML and XML.
+const (
+	htmlWhitespace = " \t\n\f\r"
+	xmlWhitespace  = " \t\n\r"
+)
+
+// Returns whether s only consists of the given whitespace characters.
+func isWhitespaceText(s, whitespace string) bool {
+	return strings.Trim(s, whitespace) == ""
+}
+
+// Returns n as an Element if it's an element node, nil otherwise.
+func htmlElementOrNil(n *html.Node) Element {
+	if n == nil || n.Type != html.ElementNode {
+		return nil
+	}
+
+	return HTMLElement{n}
+}
+
+// Returns the Element to match for the HTML node n. The root element is matched
+// for document nodes, and nil is returned for nodes other than elements.
+func htmlElement(n *html.Node) Element {
+	if n.Type == html.DocumentNode {
+		for n = n.FirstChild; n != nil; n = n.NextSibling {
+			if n.Type == html.ElementNode {
+				break
+			}
+		}
+	}
+
+	return htmlElementOrNil(n)
+}
+
+// Returns a callback function for elements invoking f with the nodes of HTMLElements.
+func htmlMatchFunc(f SimpleSelectorMatchFunc) ElementMatchFunc {
+	if f == nil {
+		return nil
+	}
+
+	return func(s SimpleSelector, e Element) bool {
+		h, ok := e.(HTMLElement)
+		return ok && f(s, h.Node)
+	}
+}
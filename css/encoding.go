@@ -0,0 +1,93 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"bytes"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// Decodes the style sheet bytes b and returns the resulting UTF-8 text, e.g. to use with NewTokenizer.
+// The protocol encoding is the encoding label given by the transport layer, e.g. the charset parameter
+// of the Content-Type header, and the environment encoding is the encoding label of the referring document.
+// Both of them may be empty if not known.
+// See http://www.w3.org/TR/css-syntax-3/#input-byte-stream
+func DecodeStylesheet(b []byte, protocolEncoding, environmentEncoding string) (string, error) {
+	e, b := sniffBOM(b)
+	if e == nil {
+		e = fallbackEncoding(b, protocolEncoding, environmentEncoding)
+	}
+
+	r, err := e.NewDecoder().Bytes(b)
+	if err != nil {
+		return "", err
+	}
+
+	return string(r), nil
+}
+
+// The byte order marks taking precedence over any other encoding information.
+var boms = []struct {
+	bom   []byte
+	label string
+}{
+	{[]byte{0xEF, 0xBB, 0xBF}, "utf-8"},
+	{[]byte{0xFE, 0xFF}, "utf-16be"},
+	{[]byte{0xFF, 0xFE}, "utf-16le"},
+}
+
+// Returns the encoding given by the byte order mark of b, if any,
+// along with b without the byte order mark.
+// See http://encoding.spec.whatwg.org/#decode
+func sniffBOM(b []byte) (encoding.Encoding, []byte) {
+	for _, x := range boms {
+		if bytes.HasPrefix(b, x.bom) {
+			e, _ := charset.Lookup(x.label)
+			return e, b[len(x.bom):]
+		}
+	}
+
+	return nil, b
+}
+
+// The start of an @charset rule. Note that it must be matched exactly, i.e. the rule
+// must be in lower case, use double quotes and a single space.
+var charsetRuleStart = []byte(`@charset "`)
+
+// Determines the encoding of the style sheet bytes b.
+// See http://www.w3.org/TR/css-syntax-3/#determine-the-fallback-encoding
+func fallbackEncoding(b []byte, protocolEncoding, environmentEncoding string) encoding.Encoding {
+	if e, _ := charset.Lookup(protocolEncoding); e != nil {
+		return e
+	}
+
+	if len(b) > 1024 {
+		b = b[:1024]
+	}
+
+	if bytes.HasPrefix(b, charsetRuleStart) {
+		b = b[len(charsetRuleStart):]
+		if i := bytes.IndexByte(b, '"'); i != -1 && bytes.HasPrefix(b[i:], []byte(`";`)) {
+			if e, name := charset.Lookup(string(b[:i])); e != nil {
+				// A style sheet claiming to be UTF-16 without a BOM must have been decoded
+				// as ASCII compatible for the @charset rule to be found.
+				if name == "utf-16be" || name == "utf-16le" {
+					e, _ = charset.Lookup("utf-8")
+				}
+
+				return e
+			}
+		}
+	}
+
+	if e, _ := charset.Lookup(environmentEncoding); e != nil {
+		return e
+	}
+
+	e, _ := charset.Lookup("utf-8")
+	return e
+}
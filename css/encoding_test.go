@@ -0,0 +1,45 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import "testing"
+
+var testDecodeStylesheet = []struct {
+	name, protocol, environment string
+	input                       []byte
+	want                        string
+}{
+	{"UTF-8 BOM", "", "", []byte("\xEF\xBB\xBFa{}"), "a{}"},
+	{"UTF-16BE BOM", "", "", []byte("\xFE\xFF\x00a\x00{\x00}"), "a{}"},
+	{"UTF-16LE BOM", "", "", []byte("\xFF\xFEa\x00{\x00}\x00"), "a{}"},
+	{"BOM over protocol", "iso-8859-1", "", []byte("\xFF\xFE\xE9\x00"), "é"},
+	{"Latin-1 protocol", "iso-8859-1", "", []byte("a:after{content:'\xE9'}"), "a:after{content:'é'}"},
+	{"Latin-1 environment", "", "latin1", []byte("\xE9"), "é"},
+	{"Latin-1 @charset", "", "", []byte("@charset \"iso-8859-1\";\xE9"), "@charset \"iso-8859-1\";é"},
+	{"Protocol over @charset", "utf-8", "", []byte("@charset \"iso-8859-1\";\xC3\xA9"), "@charset \"iso-8859-1\";é"},
+	{"@charset over environment", "", "utf-8", []byte("@charset \"iso-8859-1\";\xE9"), "@charset \"iso-8859-1\";é"},
+	{"Bogus @charset", "", "iso-8859-1", []byte("@charset \"bogus\";\xE9"), "@charset \"bogus\";é"},
+	{"Bogus protocol", "bogus", "", []byte("@charset \"iso-8859-1\";\xE9"), "@charset \"iso-8859-1\";é"},
+	{"Upper case @charset", "", "", []byte("@CHARSET \"iso-8859-1\";\xE9"), "@CHARSET \"iso-8859-1\";�"},
+	{"Single quoted @charset", "", "", []byte("@charset 'iso-8859-1';\xE9"), "@charset 'iso-8859-1';�"},
+	{"Extra space @charset", "", "", []byte("@charset  \"iso-8859-1\";\xE9"), "@charset  \"iso-8859-1\";�"},
+	{"Unterminated @charset", "", "", []byte("@charset \"iso-8859-1\"\xE9"), "@charset \"iso-8859-1\"�"},
+	{"UTF-16 @charset", "", "", []byte("@charset \"utf-16le\";\xC3\xA9"), "@charset \"utf-16le\";é"},
+	{"Default", "", "", []byte("\xC3\xA9\xFF"), "é�"},
+}
+
+func TestDecodeStylesheet(t *testing.T) {
+	for _, test := range testDecodeStylesheet {
+		r, err := DecodeStylesheet(test.input, test.protocol, test.environment)
+		if err != nil {
+			t.Errorf(`%s: Could not decode style sheet (%s)`, test.name, err)
+			continue
+		}
+
+		if r != test.want {
+			t.Errorf(`%s: Got %q, want %q`, test.name, r, test.want)
+		}
+	}
+}
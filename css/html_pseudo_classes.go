@@ -0,0 +1,541 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+// Sets the fragment identifier of the document URL, making :target match the indicated element,
+// i.e. the first element whose ID is the fragment identifier or else the first a element whose
+// name is the fragment identifier. The :target pseudo class never matches otherwise.
+// See http://www.whatwg.org/specs/web-apps/current-work/multipage/browsers.html#the-indicated-part-of-the-document
+func (r *Registry) SetTarget(fragment string) {
+	r.RegisterPseudoClass("target", func(e Element) bool {
+		return isTarget(e, fragment)
+	})
+}
+
+// Returns whether e is the element indicated by the fragment identifier.
+func isTarget(e Element, fragment string) bool {
+	if fragment == "" {
+		return false
+	}
+
+	id, _ := attributeValue(e, "id")
+	name, _ := attributeValue(e, "name")
+	if id != fragment && (name != fragment || htmlLocalName(e) != "a") {
+		return false
+	}
+
+	root := e
+	for p := root.Parent(); p != nil; p = p.Parent() {
+		root = p
+	}
+
+	var byID, byName Element
+	TraverseElements(root, func(x Element) {
+		if byID == nil {
+			if v, _ := attributeValue(x, "id"); v == fragment {
+				byID = x
+			}
+		}
+
+		if byName == nil && htmlLocalName(x) == "a" {
+			if v, _ := attributeValue(x, "name"); v == fragment {
+				byName = x
+			}
+		}
+	})
+
+	if byID != nil {
+		return e == byID
+	}
+
+	return e == byName
+}
+
+// Returns whether e is a hyperlink. Links are never visited.
+func isLink(e Element) bool {
+	switch htmlLocalName(e) {
+	case "a", "area":
+		return hasAttribute(e, "href")
+	default:
+		return false
+	}
+}
+
+// Returns whether e is a checked checkbox or radio button, or a selected option.
+func isChecked(e Element) bool {
+	switch htmlLocalName(e) {
+	case "input":
+		switch inputState(e) {
+		case "checkbox", "radio":
+			return hasAttribute(e, "checked")
+		}
+	case "option":
+		return isSelected(e)
+	}
+
+	return false
+}
+
+// Returns whether the option element e is selected. In a select element showing a drop-down box,
+// the last option with the selected attribute is selected, or else the first option not disabled.
+// See http://www.whatwg.org/specs/web-apps/current-work/multipage/forms.html#selectedness-setting-algorithm
+func isSelected(e Element) bool {
+	s := optionSelect(e)
+	if s == nil || hasAttribute(s, "multiple") || displaySize(s) > 1 {
+		return hasAttribute(e, "selected")
+	}
+
+	var selected, first Element
+	for _, o := range options(s) {
+		if hasAttribute(o, "selected") {
+			selected = o
+		}
+
+		if first == nil && !isDisabled(o) {
+			first = o
+		}
+	}
+
+	if selected != nil {
+		return e == selected
+	}
+
+	return e == first
+}
+
+// Returns the select element the option element e belongs to, nil if none.
+func optionSelect(e Element) Element {
+	p := e.Parent()
+	if p != nil && htmlLocalName(p) == "optgroup" {
+		p = p.Parent()
+	}
+
+	if p == nil || htmlLocalName(p) != "select" {
+		return nil
+	}
+
+	return p
+}
+
+// Returns the list of options of the select element s, i.e. its option children and the option children of its optgroup children.
+func options(s Element) []Element {
+	var r []Element
+	for c := s.FirstChild(); c != nil; c = c.NextSibling() {
+		switch htmlLocalName(c) {
+		case "option":
+			r = append(r, c)
+		case "optgroup":
+			for o := c.FirstChild(); o != nil; o = o.NextSibling() {
+				if htmlLocalName(o) == "option" {
+					r = append(r, o)
+				}
+			}
+		}
+	}
+
+	return r
+}
+
+// Returns the display size of the select element s.
+func displaySize(s Element) int {
+	v, ok := attributeValue(s, "size")
+	if !ok {
+		return 1
+	}
+
+	if n, ok := parseInt(v); ok && n > 0 {
+		return n
+	}
+
+	return 1
+}
+
+// Returns whether e is the default button of its form, a checkbox or radio button checked by default,
+// or an option selected by default.
+func isDefault(e Element) bool {
+	switch htmlLocalName(e) {
+	case "input":
+		switch inputState(e) {
+		case "checkbox", "radio":
+			return hasAttribute(e, "checked")
+		case "submit", "image":
+			return isDefaultButton(e)
+		}
+	case "button":
+		return inputState(e) == "submit" && isDefaultButton(e)
+	case "option":
+		return hasAttribute(e, "selected")
+	}
+
+	return false
+}
+
+// Returns whether the submit button e is the first submit button in tree order of its form owner.
+func isDefaultButton(e Element) bool {
+	f := formOwner(e)
+	if f == nil {
+		return false
+	}
+
+	var first Element
+	TraverseElements(rootElement(e), func(x Element) {
+		if first == nil && isSubmitButton(x) && formOwner(x) == f {
+			first = x
+		}
+	})
+
+	return e == first
+}
+
+// Returns whether e is a submit button.
+func isSubmitButton(e Element) bool {
+	switch htmlLocalName(e) {
+	case "button":
+		return inputState(e) == "submit"
+	case "input":
+		s := inputState(e)
+		return s == "submit" || s == "image"
+	default:
+		return false
+	}
+}
+
+// Returns the form owner of e, nil if none. The form owner of an element having a form attribute is
+// the first element in tree order having the id given by the attribute, if it's a form. Otherwise
+// it's the closest form ancestor of the element.
+// See http://www.whatwg.org/specs/web-apps/current-work/multipage/forms.html#reset-the-form-owner
+func formOwner(e Element) Element {
+	if id, ok := attributeValue(e, "form"); ok && formAssociated[htmlLocalName(e)] {
+		var owner Element
+		found := false
+		TraverseElements(rootElement(e), func(x Element) {
+			if v, _ := attributeValue(x, "id"); !found && id != "" && v == id {
+				if found = true; htmlLocalName(x) == "form" {
+					owner = x
+				}
+			}
+		})
+
+		return owner
+	}
+
+	for p := e.Parent(); p != nil; p = p.Parent() {
+		if htmlLocalName(p) == "form" {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// The elements whose form owner may be given by a form attribute.
+var formAssociated = map[string]bool{
+	"button":   true,
+	"fieldset": true,
+	"input":    true,
+	"object":   true,
+	"output":   true,
+	"select":   true,
+	"textarea": true,
+}
+
+// Returns the root element of the tree containing e.
+func rootElement(e Element) Element {
+	for e.Parent() != nil {
+		e = e.Parent()
+	}
+
+	return e
+}
+
+// Returns whether e is a form control, option, optgroup or fieldset that is disabled.
+// A form control or fieldset is also disabled if it's within a disabled fieldset,
+// unless it's within the first legend of that fieldset.
+// See http://www.whatwg.org/specs/web-apps/current-work/multipage/forms.html#concept-fe-disabled
+func isDisabled(e Element) bool {
+	switch htmlLocalName(e) {
+	case "button", "input", "select", "textarea", "fieldset":
+		return hasAttribute(e, "disabled") || inDisabledFieldset(e)
+	case "optgroup":
+		return hasAttribute(e, "disabled")
+	case "option":
+		if hasAttribute(e, "disabled") {
+			return true
+		}
+
+		p := e.Parent()
+		return p != nil && htmlLocalName(p) == "optgroup" && hasAttribute(p, "disabled")
+	default:
+		return false
+	}
+}
+
+// Returns whether e is within a fieldset with the disabled attribute, but not within its first legend child.
+func inDisabledFieldset(e Element) bool {
+	for c, p := e, e.Parent(); p != nil; c, p = p, p.Parent() {
+		if htmlLocalName(p) != "fieldset" || !hasAttribute(p, "disabled") {
+			continue
+		}
+
+		if htmlLocalName(c) != "legend" || firstLegend(p) != c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the first legend child of the fieldset f, nil if none.
+func firstLegend(f Element) Element {
+	for c := f.FirstChild(); c != nil; c = c.NextSibling() {
+		if htmlLocalName(c) == "legend" {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// Returns whether e is a form control, option, optgroup or fieldset that isn't disabled.
+func isEnabled(e Element) bool {
+	switch htmlLocalName(e) {
+	case "button", "input", "select", "textarea", "fieldset", "optgroup", "option":
+		return !isDisabled(e)
+	default:
+		return false
+	}
+}
+
+// Returns whether e is a checkbox or radio button whose state is indeterminate, or a progress element without a value.
+// A radio button is indeterminate if no radio button in its group is checked, the indeterminate IDL
+// attribute of checkboxes isn't reflected by any content attribute though.
+func isIndeterminate(e Element) bool {
+	switch htmlLocalName(e) {
+	case "input":
+		return inputState(e) == "radio" && !radioGroupChecked(e)
+	case "progress":
+		return !hasAttribute(e, "value")
+	default:
+		return false
+	}
+}
+
+// Returns whether any radio button in the radio button group of the radio button e is checked.
+// The group consists of the radio buttons with the same name and form owner within the same tree.
+// See http://www.whatwg.org/specs/web-apps/current-work/multipage/forms.html#radio-button-group
+func radioGroupChecked(e Element) bool {
+	name, _ := attributeValue(e, "name")
+	if name == "" {
+		return hasAttribute(e, "checked")
+	}
+
+	owner := formOwner(e)
+	checked := false
+	TraverseElements(rootElement(e), func(x Element) {
+		if !checked && htmlLocalName(x) == "input" && inputState(x) == "radio" && hasAttribute(x, "checked") && formOwner(x) == owner {
+			n, _ := attributeValue(x, "name")
+			checked = n == name
+		}
+	})
+
+	return checked
+}
+
+// Returns whether e is a form control with the required attribute.
+func isRequired(e Element) bool {
+	return isRequirable(e) && hasAttribute(e, "required")
+}
+
+// Returns whether e is a form control without the required attribute.
+func isOptional(e Element) bool {
+	return isRequirable(e) && !hasAttribute(e, "required")
+}
+
+// Returns whether e is an input, select or textarea element.
+func isRequirable(e Element) bool {
+	switch htmlLocalName(e) {
+	case "input", "select", "textarea":
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns whether e is an input or textarea element showing its placeholder, i.e. it has no value.
+func isPlaceholderShown(e Element) bool {
+	if !hasAttribute(e, "placeholder") {
+		return false
+	}
+
+	switch htmlLocalName(e) {
+	case "input":
+		if !placeholderStates[inputState(e)] {
+			return false
+		}
+
+		v, _ := attributeValue(e, "value")
+		return v == ""
+	case "textarea":
+		if x, ok := e.(TextContentElement); ok {
+			return x.TextContent() == ""
+		}
+
+		return e.IsEmpty()
+	default:
+		return false
+	}
+}
+
+// The input element states that the placeholder attribute applies to.
+var placeholderStates = map[string]bool{
+	"email":    true,
+	"number":   true,
+	"password": true,
+	"search":   true,
+	"tel":      true,
+	"text":     true,
+	"url":      true,
+}
+
+// Returns whether e is an HTML element that isn't mutable by the user.
+func isReadOnly(e Element) bool {
+	return isHTMLElement(e) && !isReadWrite(e)
+}
+
+// Returns whether e is a mutable text control or an editing host.
+// See http://www.whatwg.org/specs/web-apps/current-work/multipage/scripting.html#selector-read-write
+func isReadWrite(e Element) bool {
+	if !isHTMLElement(e) {
+		return false
+	}
+
+	switch htmlLocalName(e) {
+	case "input":
+		if !readOnlyStates[inputState(e)] {
+			return false
+		}
+
+		fallthrough
+	case "textarea":
+		return !hasAttribute(e, "readonly") && !isDisabled(e)
+	default:
+		return isEditable(e)
+	}
+}
+
+// The input element states that the readonly attribute applies to.
+var readOnlyStates = map[string]bool{
+	"date":           true,
+	"datetime-local": true,
+	"email":          true,
+	"month":          true,
+	"number":         true,
+	"password":       true,
+	"search":         true,
+	"tel":            true,
+	"text":           true,
+	"time":           true,
+	"url":            true,
+	"week":           true,
+}
+
+// Returns whether the HTML element e is editable, as determined by the contenteditable
+// attribute of the element itself or else its closest ancestor with the attribute.
+func isEditable(e Element) bool {
+	for x := e; x != nil && isHTMLElement(x); x = x.Parent() {
+		v, ok := attributeValue(x, "contenteditable")
+		if !ok {
+			continue
+		}
+
+		switch toASCIILower(v) {
+		case "", "true", "plaintext-only":
+			return true
+		case "false":
+			return false
+		}
+	}
+
+	return false
+}
+
+// The states of input elements keyed by the value of the type attribute.
+// See http://www.whatwg.org/specs/web-apps/current-work/multipage/forms.html#attr-input-type
+var inputStates = map[string]bool{
+	"button":         true,
+	"checkbox":       true,
+	"color":          true,
+	"date":           true,
+	"datetime-local": true,
+	"email":          true,
+	"file":           true,
+	"hidden":         true,
+	"image":          true,
+	"month":          true,
+	"number":         true,
+	"password":       true,
+	"radio":          true,
+	"range":          true,
+	"reset":          true,
+	"search":         true,
+	"submit":         true,
+	"tel":            true,
+	"text":           true,
+	"time":           true,
+	"url":            true,
+	"week":           true,
+}
+
+// Returns the state of the input or button element e as given by its type attribute.
+// An input element with a missing or invalid type is a text field, and a button element
+// with a type other than reset or button is a submit button.
+func inputState(e Element) string {
+	v := inputType(e)
+	if htmlLocalName(e) == "button" {
+		if v == "reset" || v == "button" {
+			return v
+		}
+
+		return "submit"
+	}
+
+	if !inputStates[v] {
+		return "text"
+	}
+
+	return v
+}
+
+// Returns the lowercased local name of e if it's an HTML element in an HTML document, the empty string otherwise.
+func htmlLocalName(e Element) string {
+	if !isHTMLElement(e) {
+		return ""
+	}
+
+	return toASCIILower(e.LocalName())
+}
+
+// Returns the lowercased value of the type attribute of e.
+func inputType(e Element) string {
+	v, _ := attributeValue(e, "type")
+	return toASCIILower(v)
+}
+
+// Returns whether e has an attribute with the given name and no namespace.
+func hasAttribute(e Element, name string) bool {
+	_, ok := attributeValue(e, name)
+	return ok
+}
+
+// Returns the value of the attribute of e with the given name and no namespace, and whether it exists.
+func attributeValue(e Element, name string) (string, bool) {
+	for i, n := 0, e.NumAttributes(); i < n; i++ {
+		if a := e.Attribute(i); a.Namespace == "" && a.Name == name {
+			return a.Value, true
+		}
+	}
+
+	return "", false
+}
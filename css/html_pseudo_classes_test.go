@@ -0,0 +1,148 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const formHTML = `<!DOCTYPE html>
+<html><body>
+<a id="a1" href="/x">x</a><a id="a2" name="top">top</a><area id="ar" href="/y"><link id="l1" href="s.css">
+<form id="f">
+	<input id="i1" required>
+	<input id="i2" type="checkbox" checked disabled>
+	<input id="i3" type="radio" name="r">
+	<input id="i4" type="radio" name="r">
+	<input id="i5" type="radio" name="q" checked>
+	<input id="i6" type="text" readonly placeholder="p">
+	<input id="i7" type="email" placeholder="p" value="">
+	<input id="i8" type="range" placeholder="p">
+	<input id="i9" type="submit">
+	<button id="b1">b</button>
+	<textarea id="t1" placeholder="p"></textarea>
+	<textarea id="t2" placeholder="p">x</textarea>
+	<fieldset id="fs1" disabled>
+		<legend id="lg1"><input id="i10"></legend>
+		<legend id="lg2"><input id="i11"></legend>
+		<select id="s1" required><option id="o1" disabled>1</option><option id="o2">2</option></select>
+		<fieldset id="fs2"><input id="i12"></fieldset>
+	</fieldset>
+	<select id="s2"><option id="o3" selected>3</option><optgroup id="og" disabled><option id="o4" selected>4</option></optgroup></select>
+	<select id="s3" multiple><option id="o5" selected>5</option><option id="o6" selected>6</option></select>
+	<progress id="p1"></progress><progress id="p2" value="1"></progress>
+</form>
+<div id="ce" contenteditable><p id="cep">e</p><span id="ro" contenteditable="false">f</span></div>
+</body></html>`
+
+var testFormSelectors = map[string]string{
+	`:link`:                   "a1 ar",
+	`:any-link`:               "a1 ar",
+	`:checked`:                "i2 i5 o2 o4 o5 o6",
+	`:default`:                "i2 i5 i9 o3 o4 o5 o6",
+	`:disabled`:               "i2 fs1 i11 s1 o1 fs2 i12 og o4",
+	`:enabled`:                "i1 i3 i4 i5 i6 i7 i8 i9 b1 t1 t2 i10 o2 s2 o3 s3 o5 o6",
+	`input:enabled[required]`: "i1",
+	`:required`:               "i1 s1",
+	`:optional`:               "i2 i3 i4 i5 i6 i7 i8 i9 t1 t2 i10 i11 i12 s2 s3",
+	`:indeterminate`:          "i3 i4 p1",
+	`:placeholder-shown`:      "i6 i7 t1",
+	`form :read-write`:        "i1 i7 t1 t2 i10",
+	`:read-write`:             "i1 i7 t1 t2 i10 ce cep",
+	`div :read-only`:          "ro",
+	`:target`:                 "",
+}
+
+func TestFormPseudoClasses(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(formHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range testFormSelectors {
+		s, err := ParseSelectorWithOptions(NewTokenizer(k), ParserOptions{Registry: NewRegistry()})
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		if ids := nodeIDs(QueryAll(s, doc)); ids != v {
+			t.Errorf(`Got %q matching %q, want %q`, ids, k, v)
+		}
+
+		checkCompiledMatching(t, s, k, doc, nil)
+	}
+}
+
+func TestFormAttribute(t *testing.T) {
+	tests := []struct {
+		html, selector, ids string
+	}{
+		{`<form id="f"></form><button id="b1" form="f"></button><form><button id="b2"></button></form>`, `:default`, "b1 b2"},
+		{`<form id="f"><button id="b1" form="g"></button><button id="b2"></button></form><form id="g"></form>`, `:default`, "b1 b2"},
+		{`<form id="f"><button id="b1" form="x"></button><button id="b2"></button></form>`, `:default`, "b2"},
+		{`<div id="d"></div><form><button id="b1" form="d"></button></form>`, `:default`, ""},
+		{`<form id="f"><button id="b1" form=""></button><button id="b2"></button></form>`, `:default`, "b2"},
+		{`<form id="f"><input id="r1" type="radio" name="r" checked></form><input id="r2" type="radio" name="r" form="f"><input id="r3" type="radio" name="r">`, `:indeterminate`, "r3"},
+		{`<form id="f"><input id="r1" type="radio" name="r" form="g"></form><form id="g"><input id="r2" type="radio" name="r" checked></form>`, `:indeterminate`, ""},
+		{`<form id="f"><input id="r1" type="radio" name="r" form="g"><input id="r2" type="radio" name="r" checked></form><form id="g"></form>`, `:indeterminate`, "r1"},
+	}
+
+	for _, test := range tests {
+		doc, err := html.Parse(strings.NewReader(test.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := ParseSelectorFromString(test.selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ids := nodeIDs(QueryAll(s, doc)); ids != test.ids {
+			t.Errorf(`Got %q matching %q in %q, want %q`, ids, test.selector, test.html, test.ids)
+		}
+
+		checkCompiledMatching(t, s, test.selector, doc, nil)
+	}
+}
+
+func TestTargetPseudoClass(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(formHTML + `<p id="top"></p><p id="top"></p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"":    "",
+		"f":   "f",
+		"top": "top",
+		"i1":  "i1",
+		"nil": "",
+	}
+
+	for fragment, v := range tests {
+		r := NewRegistry()
+		r.SetTarget(fragment)
+		nodes, err := QuerySelectorAllWithOptions(`:target`, doc, ParserOptions{Registry: r})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ids := nodeIDs(nodes); ids != v {
+			t.Errorf(`Got %q matching ":target" with fragment %q, want %q`, ids, fragment, v)
+		}
+	}
+
+	doc, _ = html.Parse(strings.NewReader(formHTML))
+	r := NewRegistry()
+	r.SetTarget("top")
+	if nodes, _ := QuerySelectorAllWithOptions(`:target`, doc, ParserOptions{Registry: r}); nodeIDs(nodes) != "a2" {
+		t.Errorf(`Got %q matching ":target" with fragment "top", want the a element named top`, nodeIDs(nodes))
+	}
+}
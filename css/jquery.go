@@ -0,0 +1,336 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// The jQuery positional pseudo classes, without and with an index argument.
+var (
+	positionalClasses   = map[string]bool{"first": true, "last": true, "even": true, "odd": true}
+	positionalFunctions = map[string]bool{"eq": true, "gt": true, "lt": true}
+)
+
+// Registers the jQuery extension pseudo classes in the Registry r, making it possible to run
+// selectors written for jQuery unchanged. The following pseudo classes are registered:
+//
+//	:contains(text), :header, :input, :button, :parent, :text, :checkbox, :radio, :password,
+//	:file, :image, :submit, :reset, and the positional :first, :last, :even, :odd, :eq(index),
+//	:gt(index) and :lt(index).
+//
+// The positional pseudo classes select elements by their zero-based index in the set of elements,
+// in document order, matched by the part of the selector preceding them, e.g. `div p:first` selects
+// the first p element within a div while `div:first p` selects the p elements within the first div.
+// A negative index counts from the end of the set. They're evaluated when querying, i.e. by Query,
+// QueryAll and the like, and never match when matching a single element. They're syntax errors within
+// other pseudo classes, e.g. :not(p:first) or :nth-child(1 of p:first).
+// See http://api.jquery.com/category/selectors/jquery-selector-extensions/
+func RegisterJQueryExtensions(r *Registry) {
+	r.positional = true
+	r.RegisterPseudoFunction("contains", parseContainsArguments, matchesContains)
+	for name, f := range jqueryPseudoClassMatchers {
+		r.RegisterPseudoClass(name, f)
+	}
+}
+
+// Represents an Element able to return its text content, i.e. the concatenated
+// character data of the text nodes within it. Used when matching :contains().
+type TextContentElement interface {
+	Element
+	TextContent() string // The text content of the element.
+}
+
+// Parses the text argument of :contains(), either a string or the source text of the arguments as is.
+func parseContainsArguments(args []ComponentValue) (interface{}, error) {
+	if len(args) == 1 && isToken(args[0], String) {
+		return args[0].(Token).String(), nil
+	}
+
+	var b bytes.Buffer
+	for _, x := range args {
+		tk, ok := x.(Token)
+		if !ok {
+			return nil, errors.New("Expected text")
+		}
+
+		b.WriteString(tk.SourceText())
+	}
+
+	if b.Len() == 0 {
+		return nil, errors.New("Expected text")
+	}
+
+	return b.String(), nil
+}
+
+// Returns whether the text content of e contains the text.
+func matchesContains(text interface{}, e Element) bool {
+	x, ok := e.(TextContentElement)
+	return ok && strings.Contains(x.TextContent(), text.(string))
+}
+
+// The functions used to match the non-positional jQuery extension pseudo classes without arguments.
+var jqueryPseudoClassMatchers = map[string]PseudoClassMatchFunc{
+	"header": func(e Element) bool {
+		switch htmlLocalName(e) {
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			return true
+		default:
+			return false
+		}
+	},
+	"input": func(e Element) bool {
+		switch htmlLocalName(e) {
+		case "input", "select", "textarea", "button":
+			return true
+		default:
+			return false
+		}
+	},
+	"button": func(e Element) bool {
+		n := htmlLocalName(e)
+		return n == "button" || (n == "input" && inputType(e) == "button")
+	},
+	"parent": func(e Element) bool {
+		// Unlike :empty, whitespace counts as content.
+		if x, ok := e.(TextContentElement); ok {
+			return e.FirstChild() != nil || x.TextContent() != ""
+		}
+
+		return !e.IsEmpty()
+	},
+	"text": func(e Element) bool {
+		if htmlLocalName(e) != "input" {
+			return false
+		}
+
+		// Unlike the type attribute selector, an input element without a type attribute matches.
+		_, ok := attributeValue(e, "type")
+		return !ok || inputType(e) == "text"
+	},
+	"checkbox": inputOfType("checkbox"),
+	"radio":    inputOfType("radio"),
+	"password": inputOfType("password"),
+	"file":     inputOfType("file"),
+	"image":    inputOfType("image"),
+	"submit":   buttonOfType("submit"),
+	"reset":    buttonOfType("reset"),
+}
+
+// Returns a function matching input elements of the given type.
+func inputOfType(typ string) PseudoClassMatchFunc {
+	return func(e Element) bool {
+		return htmlLocalName(e) == "input" && inputType(e) == typ
+	}
+}
+
+// Returns a function matching input and button elements in the given state, e.g. a button
+// element without a type attribute is a submit button.
+func buttonOfType(typ string) PseudoClassMatchFunc {
+	return func(e Element) bool {
+		n := htmlLocalName(e)
+		return (n == "input" || n == "button") && inputState(e) == typ
+	}
+}
+
+// Returns the elements of set selected by this positional pseudo class.
+func (s *PseudoPositionalSelector) filter(set []Element) []Element {
+	index := func() int {
+		if s.Index < 0 {
+			return s.Index + len(set)
+		}
+
+		return s.Index
+	}
+
+	switch toASCIILower(s.Name) {
+	case "first":
+		if len(set) > 1 {
+			return set[:1]
+		}
+
+		return set
+	case "last":
+		if len(set) > 1 {
+			return set[len(set)-1:]
+		}
+
+		return set
+	case "even", "odd":
+		var r []Element
+		i := 0
+		if toASCIILower(s.Name) == "odd" {
+			i = 1
+		}
+
+		for ; i < len(set); i += 2 {
+			r = append(r, set[i])
+		}
+
+		return r
+	case "eq":
+		if i := index(); i >= 0 && i < len(set) {
+			return set[i : i+1]
+		}
+
+		return nil
+	case "gt":
+		i := index() + 1
+		if i < 0 {
+			i = 0
+		} else if i > len(set) {
+			i = len(set)
+		}
+
+		return set[i:]
+	case "lt":
+		i := index()
+		if i < 0 {
+			i = 0
+		} else if i > len(set) {
+			i = len(set)
+		}
+
+		return set[:i]
+	default:
+		return nil
+	}
+}
+
+// Returns whether any of the compound selectors of the selectors in g have positional pseudo classes.
+func hasPositional(g SelectorsGroup) bool {
+	for _, s := range g {
+		if selectorHasPositional(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns whether any of the compound selectors of s have positional pseudo classes.
+func selectorHasPositional(s *Selector) bool {
+	for c := s.CompoundSelector; c != nil; {
+		for _, x := range c.SimpleSelectors {
+			if _, ok := x.(*PseudoPositionalSelector); ok {
+				return true
+			}
+		}
+
+		if c.Prev == nil {
+			break
+		}
+
+		c = c.Prev.CompoundSelector
+	}
+
+	return false
+}
+
+// Returns the elements of all, given in document order, that match the selectors in g
+// evaluating the positional pseudo classes of the selectors.
+func (m *matchState) queryPositional(g SelectorsGroup, all []Element) []Element {
+	var plain SelectorsGroup
+	selected := map[Element]bool{}
+	for _, s := range g {
+		if !selectorHasPositional(s) {
+			plain = append(plain, s)
+			continue
+		}
+
+		for _, e := range m.selectPositional(s, all) {
+			selected[e] = true
+		}
+	}
+
+	var result []Element
+	for _, e := range all {
+		if selected[e] || m.matchesSelectors(plain, e) {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
+
+// Returns the elements of all, given in document order, that match the selector s, which has positional
+// pseudo classes. The compound selectors are evaluated from left to right, each one selecting the elements
+// related to the ones selected by the preceding compound selector and then filtering them by position.
+func (m *matchState) selectPositional(s *Selector, all []Element) []Element {
+	if s.PseudoElement != nil {
+		return nil
+	}
+
+	var compounds []*CompoundSelector
+	for c := s.CompoundSelector; c != nil; {
+		compounds = append([]*CompoundSelector{c}, compounds...)
+		if c.Prev == nil {
+			break
+		}
+
+		c = c.Prev.CompoundSelector
+	}
+
+	var set []Element
+	for i, c := range compounds {
+		var simple []SimpleSelector
+		var positional []*PseudoPositionalSelector
+		for _, x := range c.SimpleSelectors {
+			if p, ok := x.(*PseudoPositionalSelector); ok {
+				positional = append(positional, p)
+			} else {
+				simple = append(simple, x)
+			}
+		}
+
+		var prev map[Element]bool
+		if i > 0 {
+			prev = make(map[Element]bool, len(set))
+			for _, e := range set {
+				prev[e] = true
+			}
+		}
+
+		set = nil
+		for _, e := range all {
+			if (i == 0 || relatesToAny(e, prev, c.Prev.Combinator)) && m.matchesSimpleSelectors(simple, e) {
+				set = append(set, e)
+			}
+		}
+
+		for _, p := range positional {
+			set = p.filter(set)
+		}
+	}
+
+	return set
+}
+
+// Returns whether e is related to any of the elements in the set by the combinator c.
+func relatesToAny(e Element, set map[Element]bool, c Combinator) bool {
+	switch c {
+	case Child:
+		return set[e.Parent()]
+	case NextSibling:
+		return set[e.PrevSibling()]
+	case LaterSibling:
+		for x := e.PrevSibling(); x != nil; x = x.PrevSibling() {
+			if set[x] {
+				return true
+			}
+		}
+	default:
+		for x := e.Parent(); x != nil; x = x.Parent() {
+			if set[x] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
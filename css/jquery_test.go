@@ -0,0 +1,148 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const jqueryHTML = `<!DOCTYPE html>
+<html><body>
+<h1 id="h1">Title</h1>
+<div id="d1"><p id="p1">one</p><p id="p2">two <b>bold</b></p></div>
+<div id="d2"><p id="p3">three</p><p id="p4">four</p><p id="p5">five</p></div>
+<h2 id="h2">Sub</h2>
+<form id="f">
+	<input id="i1">
+	<input id="i2" type="TEXT">
+	<input id="i3" type="checkbox">
+	<input id="i4" type="button">
+	<input id="i5" type="submit">
+	<button id="b1">Go</button>
+	<button id="b2" type="reset">Reset</button>
+	<select id="s1"></select>
+	<textarea id="t1"></textarea>
+	<span id="empty"></span>
+</form>
+</body></html>`
+
+var testJQuerySelectors = map[string]string{
+	`p:first`:                        "p1",
+	`p:last`:                         "p5",
+	`p:eq(2)`:                        "p3",
+	`p:eq(-1)`:                       "p5",
+	`p:eq(9)`:                        "",
+	`p:gt(2)`:                        "p4 p5",
+	`p:gt(-3)`:                       "p4 p5",
+	`p:lt(2)`:                        "p1 p2",
+	`p:lt(-4)`:                       "p1",
+	`p:even`:                         "p1 p3 p5",
+	`p:odd`:                          "p2 p4",
+	`div p:first`:                    "p1",
+	`div:first p`:                    "p1 p2",
+	`div:last > p:odd`:               "p4",
+	`div:eq(1) p:eq(1)`:              "p4",
+	`div:first ~ div`:                "d2",
+	`div:first + div p:last`:         "p5",
+	`p:gt(0):lt(2)`:                  "p2 p3",
+	`p.x:first`:                      "",
+	`h1:first, p:last`:               "h1 p5",
+	`p:last, #p1`:                    "p1 p5",
+	`:contains(two)`:                 "html body d1 p2",
+	`p:contains("four")`:             "p4",
+	`p:contains(bold)`:               "p2",
+	`:header`:                        "h1 h2",
+	`:input`:                         "i1 i2 i3 i4 i5 b1 b2 s1 t1",
+	`:button`:                        "i4 b1 b2",
+	`:text`:                          "i1 i2",
+	`:checkbox`:                      "i3",
+	`:submit`:                        "i5 b1",
+	`:reset`:                         "b2",
+	`button:not([type]):submit`:      "b1",
+	`form :parent`:                   "b1 b2",
+	`div:has(b)`:                     "d1",
+	`:not(:header):contains(S)`:      "html body",
+	`p:nth-child(1 of :contains(f))`: "p4",
+}
+
+func TestJQueryExtensions(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(jqueryHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRegistry()
+	RegisterJQueryExtensions(r)
+	o := ParserOptions{Registry: r}
+
+	for k, v := range testJQuerySelectors {
+		all, err := QuerySelectorAllWithOptions(k, doc, o)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		if ids := nodeIDs(all); ids != v {
+			t.Errorf(`Got %q matching %q, want %q`, ids, k, v)
+		}
+
+		first, _ := QuerySelectorWithOptions(k, doc, o)
+		if id, want := nodeID(first), strings.SplitN(v+" ", " ", 2)[0]; id != want {
+			t.Errorf(`Got %q as the first node matching %q, want %q`, id, k, want)
+		}
+
+		s, _ := ParseSelectorWithOptions(NewTokenizer(k), o)
+		m, err := Compile(s)
+		if err != nil {
+			t.Errorf(`Could not compile selector %q (%s)`, k, err)
+		} else if ids := nodeIDs(m.QueryAll(doc)); ids != v {
+			t.Errorf(`Got %q matching compiled %q, want %q`, ids, k, v)
+		}
+
+		if x := nodeIDs(nodes(QueryAllElements(s, htmlElement(doc)))); x != v {
+			t.Errorf(`Got %q elements matching %q, want %q`, x, k, v)
+		}
+	}
+}
+
+func TestJQuerySerialization(t *testing.T) {
+	r := NewRegistry()
+	RegisterJQueryExtensions(r)
+	tests := map[string]string{
+		`p:FIRST`:          `p:FIRST`,
+		`p:eq( -1 )`:       `p:eq(-1)`,
+		`div:gt(+2) > p`:   `div:gt(2) > p`,
+		`:contains( a b )`: `:contains(a b)`,
+	}
+
+	for k, v := range tests {
+		s, err := ParseSelectorWithOptions(NewTokenizer(k), ParserOptions{Registry: r})
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+		} else if x := s.String(); x != v {
+			t.Errorf(`Got %q serializing %q, want %q`, x, k, v)
+		}
+	}
+
+	for _, k := range []string{`p:eq()`, `p:eq(1.5)`, `p:eq(a)`, `p:gt(1 2)`, `p:contains()`, `p:first()`, `p:eq`,
+		`:not(p:first)`, `:is(p:first)`, `:where(div, p:eq(1))`, `div:has(p:first)`, `:nth-child(1 of p:first)`,
+		`:is(div, :not(p:last))`, `:nth-last-child(odd of :is(p:lt(2)))`} {
+		if _, err := ParseSelectorWithOptions(NewTokenizer(k), ParserOptions{Registry: r}); err == nil {
+			t.Errorf(`Expected error parsing %q`, k)
+		}
+	}
+
+	_, err := ParseSelectorWithOptions(NewTokenizer(`div, :is(a, p:first)`), ParserOptions{Registry: r})
+	if e, ok := err.(*SyntaxError); !ok || e.Start.Offset != 14 || e.End.Offset != 19 {
+		t.Errorf(`Got error %v parsing a positional pseudo class in :is(), want a syntax error spanning 14-19`, err)
+	}
+
+	if _, err := ParseSelectorWithOptions(NewTokenizer(`p:first`), ParserOptions{Registry: NewRegistry()}); err == nil {
+		t.Errorf(`Expected error parsing "p:first" without the jQuery extensions`)
+	}
+}
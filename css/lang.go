@@ -0,0 +1,142 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import "strings"
+
+// Returns whether the language of e matches any of the language ranges of s.
+func (m *matchState) matchesPseudoLangSelector(s *PseudoLangSelector, e Element) bool {
+	lang, ok := m.elementLanguage(e)
+	if !ok {
+		lang = s.defaultLanguage
+	}
+
+	for _, r := range s.Ranges {
+		if matchesLanguageRange(lang, r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the language of e given by the xml:lang or lang attribute of e or its closest ancestor having one,
+// or else by the Content-Language pragma of the document, and whether a language was found.
+// The lang attribute only applies to elements in HTML documents and xml:lang takes precedence over it.
+// See http://www.whatwg.org/specs/web-apps/current-work/multipage/dom.html#language
+func (m *matchState) elementLanguage(e Element) (string, bool) {
+	root := e
+	for x := e; x != nil; x = x.Parent() {
+		if v, ok := namespacedAttributeValue(x, XMLNamespace, "lang"); ok {
+			return v, true
+		}
+
+		if x.IsHTML() {
+			if v, ok := attributeValue(x, "lang"); ok {
+				return v, true
+			}
+		}
+
+		root = x
+	}
+
+	return m.pragmaLanguage(root)
+}
+
+// The language set by the Content-Language pragma of a document, and whether one was found.
+type pragma struct {
+	lang  string
+	found bool
+}
+
+// Returns the language set by the Content-Language pragma of the document having the given root element.
+// The results are cached since the language is needed for every element not having a lang attribute,
+// and finding it means searching the whole document.
+func (m *matchState) pragmaLanguage(root Element) (string, bool) {
+	if p, ok := m.pragmas[root]; ok {
+		return p.lang, p.found
+	}
+
+	lang, found := pragmaLanguage(root)
+	if m.pragmas == nil {
+		m.pragmas = make(map[Element]pragma)
+	}
+
+	m.pragmas[root] = pragma{lang, found}
+	return lang, found
+}
+
+// Returns the language set by the last <meta http-equiv="content-language"> element within the root element,
+// if its content is a single language, and whether a language was found. The language is the first
+// whitespace-separated token of the content, which mustn't contain commas.
+// See http://www.whatwg.org/specs/web-apps/current-work/multipage/semantics.html#pragma-set-default-language
+func pragmaLanguage(root Element) (string, bool) {
+	if !root.IsHTML() {
+		return "", false
+	}
+
+	lang, found := "", false
+	TraverseElements(root, func(x Element) {
+		if htmlLocalName(x) != "meta" {
+			return
+		}
+
+		if v, _ := attributeValue(x, "http-equiv"); toASCIILower(v) != "content-language" {
+			return
+		}
+
+		if v, ok := attributeValue(x, "content"); ok && !strings.Contains(v, ",") {
+			if v, _ = nextField(v, 0); v != "" {
+				lang, found = v, true
+			}
+		}
+	})
+
+	return lang, found
+}
+
+// Returns the value of the attribute of e with the given namespace and name, and whether it exists.
+func namespacedAttributeValue(e Element, namespace, name string) (string, bool) {
+	for i, n := 0, e.NumAttributes(); i < n; i++ {
+		if a := e.Attribute(i); a.Namespace == namespace && a.Name == name {
+			return a.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// Returns whether the language tag matches the language range using extended filtering.
+// An empty language range only matches an empty language tag, i.e. an unknown language.
+// See http://tools.ietf.org/html/rfc4647#section-3.3.2
+func matchesLanguageRange(tag, lang string) bool {
+	if tag == "" || lang == "" {
+		return tag == lang
+	}
+
+	t := strings.Split(toASCIILower(tag), "-")
+	r := strings.Split(toASCIILower(lang), "-")
+	if r[0] != "*" && r[0] != t[0] {
+		return false
+	}
+
+	t, r = t[1:], r[1:]
+	for len(r) > 0 {
+		switch {
+		case r[0] == "*":
+			r = r[1:]
+		case len(t) == 0:
+			return false
+		case r[0] == t[0]:
+			t, r = t[1:], r[1:]
+		case len(t[0]) == 1:
+			return false
+		default:
+			t = t[1:]
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,141 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const langHTML = `<!DOCTYPE html>
+<html lang="en-US"><body>
+<p id="p1">x</p>
+<div id="d1" lang="de-Latn-CH"><p id="p2">x</p><p id="p3" lang="">x</p></div>
+<div id="d2" lang="DE-ch-1996"><svg id="s1" xml:lang="fr"><g id="g1"></g></svg></div>
+<div id="d3" lang="de-x-ch"><p id="p4" lang="zh-Hant-TW">x</p></div>
+</body></html>`
+
+var testLangSelectors = map[string]string{
+	`p:lang(en)`:           "p1",
+	`p:lang("en-US")`:      "p1",
+	`p:lang(EN-us)`:        "p1",
+	`p:lang(en-GB)`:        "",
+	`:lang(de)`:            "d1 p2 d2 d3",
+	`:lang(de-CH)`:         "d1 p2 d2",
+	`:lang("*-CH")`:        "d1 p2 d2",
+	`:lang('*-Latn')`:      "d1 p2",
+	`:lang(de-\*-CH)`:      "d1 p2 d2",
+	`:lang(de-x-fr)`:       "",
+	`:lang(zh-TW)`:         "p4",
+	`:lang(zh-Hant)`:       "p4",
+	`:lang(zh-Hans)`:       "",
+	`:lang(fr)`:            "s1 g1",
+	`:lang("")`:            "p3",
+	`:lang(fr, "", zh)`:    "p3 s1 g1 p4",
+	`:not(:lang("*"))`:     "p3",
+	`div:lang(de-CH-1996)`: "d2",
+}
+
+func TestLangPseudoClass(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(langHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range testLangSelectors {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		if ids := nodeIDs(QueryAll(s, doc)); ids != v {
+			t.Errorf(`Got %q matching %q, want %q`, ids, k, v)
+		}
+
+		checkCompiledMatching(t, s, k, doc, nil)
+	}
+}
+
+func TestDefaultLanguage(t *testing.T) {
+	tests := []struct {
+		html, lang, selector, ids string
+	}{
+		{`<p id="p1"></p>`, "", `:lang(en)`, ""},
+		{`<p id="p1"></p>`, "", `p:lang("")`, "p1"},
+		{`<p id="p1"></p>`, "en-GB", `p:lang(en)`, "p1"},
+		{`<meta http-equiv="Content-Language" content="sv"><p id="p1"></p>`, "en", `p:lang(sv)`, "p1"},
+		{`<meta http-equiv="content-language" content="sv, en"><p id="p1"></p>`, "en", `p:lang(en)`, "p1"},
+		{`<meta http-equiv="content-language" content="sv"><p id="p1" lang="fi"></p>`, "", `p:lang(fi)`, "p1"},
+		{`<meta http-equiv="content-language" content="en fr"><p id="p1"></p>`, "", `p:lang(en)`, "p1"},
+		{`<meta http-equiv="content-language" content="en fr"><p id="p1"></p>`, "", `p:lang(fr)`, ""},
+		{`<meta http-equiv="content-language" content=" sv"><p id="p1"></p><p id="p2"></p>`, "", `p:lang(sv)`, "p1 p2"},
+	}
+
+	for _, test := range tests {
+		doc, err := html.Parse(strings.NewReader(test.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := NewRegistry()
+		r.SetDefaultLanguage(test.lang)
+		nodes, err := QuerySelectorAllWithOptions(test.selector, doc, ParserOptions{Registry: r})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ids := nodeIDs(nodes); ids != test.ids {
+			t.Errorf(`Got %q matching %q in %q with default language %q, want %q`, ids, test.selector, test.html, test.lang, test.ids)
+		}
+	}
+}
+
+func TestXMLLang(t *testing.T) {
+	doc, err := ParseXML(strings.NewReader(`<a lang="de"><b xml:lang="en-CA"><c/></b><d/></a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]int{
+		`:lang(en)`: 2,
+		`:lang(de)`: 0,
+		`:lang("")`: 2,
+	}
+
+	for k, v := range tests {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r := QueryAllElements(s, XMLRootElement(doc)); len(r) != v {
+			t.Errorf(`Got %d elements matching %q, want %d`, len(r), k, v)
+		}
+	}
+}
+
+func TestLangParseErrors(t *testing.T) {
+	tests := map[string]string{
+		`:lang()`:       "Invalid :lang() argument at line 1, column 7",
+		`:lang(en,)`:    "Invalid :lang() argument at line 1, column 10",
+		`:lang(1)`:      "Invalid :lang() argument at line 1, column 7",
+		`:lang(en fr)`:  "Invalid :lang() argument at line 1, column 7",
+		`:dir()`:        "Invalid :dir() argument at line 1, column 6",
+		`:dir("ltr")`:   "Invalid :dir() argument at line 1, column 6",
+		`:dir(ltr rtl)`: "Invalid :dir() argument at line 1, column 6",
+	}
+
+	for k, v := range tests {
+		_, err := ParseSelectorFromString(k)
+		if err == nil {
+			t.Errorf(`Expected error parsing %q`, k)
+		} else if err.Error() != v {
+			t.Errorf(`Got error %q parsing %q, want %q`, err, k, v)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import "golang.org/x/net/html"
+
+// Well known namespace URLs.
+// See http://www.w3.org/TR/html5/infrastructure.html#namespaces
+const (
+	XHTMLNamespace  = "http://www.w3.org/1999/xhtml"
+	SVGNamespace    = "http://www.w3.org/2000/svg"
+	MathMLNamespace = "http://www.w3.org/1998/Math/MathML"
+	XLinkNamespace  = "http://www.w3.org/1999/xlink"
+	XMLNamespace    = "http://www.w3.org/XML/1998/namespace"
+	XMLNSNamespace  = "http://www.w3.org/2000/xmlns/"
+)
+
+// Returns the namespace URL of the element node n.
+// The HTML parser uses short names for the namespaces of foreign elements
+// and an empty namespace for HTML elements.
+func elementNamespace(n *html.Node) string {
+	switch n.Namespace {
+	case "":
+		return XHTMLNamespace
+	case "svg":
+		return SVGNamespace
+	case "math":
+		return MathMLNamespace
+	default:
+		return n.Namespace
+	}
+}
+
+// Returns the namespace URL of the attribute a.
+// The HTML parser uses short names for the namespaces of adjusted foreign attributes
+// and an empty namespace for attributes without a namespace.
+func attributeNamespace(a *html.Attribute) string {
+	switch a.Namespace {
+	case "xlink":
+		return XLinkNamespace
+	case "xml":
+		return XMLNamespace
+	case "xmlns":
+		return XMLNSNamespace
+	default:
+		return a.Namespace
+	}
+}
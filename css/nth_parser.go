@@ -5,43 +5,173 @@
 package css
 
 import (
+	"bytes"
 	"fmt"
 	"strconv"
 	"strings"
 )
 
-// Parse an An+B notation at the current position in Tokenizer t.
+// Represents an An+B notation, optionally followed by an "of S" clause
+// restricting the elements being counted to those matching the selector list S.
+// See http://dev.w3.org/csswg/css-syntax/#anb and http://dev.w3.org/csswg/selectors-4/#the-nth-child-pseudo
+type AnPlusB struct {
+	A, B int            // The A and B values.
+	Of   SelectorsGroup // The selector list of the "of S" clause, or nil if there is none.
+}
+
+// Parses the An+B notation, optionally followed by an "of S" clause, in the string s.
+// Returns a *SyntaxError if s is not valid.
+func ParseAnPlusB(s string) (*AnPlusB, error) {
+	t := withoutComments(NewTokenizer(s))
+	l := &tokenList{}
+	for {
+		tk := t.NextToken()
+		if tk.Type() == EOF {
+			l.end = tk.Position()
+			break
+		}
+
+		l.tokens = append(l.tokens, tk)
+	}
+
+	p := &selectorParser{tokenizer: l}
+	return p.parseAnPlusB(l)
+}
+
+// Returns whether the 1-based index matches this An+B notation,
+// i.e. if index equals An+B for some non-negative integer n.
+// The "of S" clause is not considered here.
+func (x *AnPlusB) Matches(index int) bool {
+	return matchesAnPlusB(x.A, x.B, index)
+}
+
+// Returns the serialization of this An+B notation in its normalized form,
+// followed by the "of S" clause if there is one.
+// See http://dev.w3.org/csswg/css-syntax/#serializing-anb
+func (x *AnPlusB) String() string {
+	var b bytes.Buffer
+	writeAnPlusB(&b, x.A, x.B)
+	if x.Of != nil {
+		b.WriteString(" of ")
+		b.WriteString(x.Of.String())
+	}
+
+	return b.String()
+}
+
+// Parse an An+B notation, optionally followed by an "of S" clause, from the tokens of l.
+func (p *selectorParser) parseAnPlusB(l *tokenList) (*AnPlusB, error) {
+	nth := l
+	var of *tokenList
+	for i, tk := range l.tokens {
+		if tk.Type() == Ident && strings.EqualFold(tk.String(), "of") {
+			nth = &tokenList{tokens: l.tokens[:i], end: tk.Position()}
+			of = &tokenList{tokens: l.tokens[i+1:], end: l.end}
+			break
+		}
+	}
+
+	a, b, err := parseNth(nth)
+	if err != nil {
+		return nil, err
+	}
+
+	x := &AnPlusB{A: a, B: b}
+	if of == nil {
+		return x, nil
+	}
+
+	n := &selectorParser{
+		tokenizer:  of,
+		namespaces: p.namespaces,
+		registry:   p.registry,
+		nested:     true,
+	}
+
+	start := n.position()
+	if x.Of, err = n.parseSelectorList(); err != nil {
+		return nil, err
+	}
+
+	for _, s := range x.Of {
+		if s.PseudoElement != nil {
+			return nil, &SyntaxError{
+				Msg:   fmt.Sprintf("Unexpected pseudo element %s in selector list", s.PseudoElement),
+				Start: start,
+				End:   n.position(),
+			}
+		}
+	}
+
+	return x, nil
+}
+
+// Returns whether the 1-based index i equals an+b for some non-negative integer n.
+// The distance between i and b is computed using unsigned integers since it overflows
+// an int when b is clamped to the minimum or maximum int.
+func matchesAnPlusB(a, b, i int) bool {
+	switch {
+	case a == 0:
+		return i == b
+	case a > 0:
+		return i >= b && (uint64(i)-uint64(b))%uint64(a) == 0
+	default:
+		return i <= b && (uint64(b)-uint64(i))%uint64(-a) == 0
+	}
+}
+
+// Writes the normalized serialization of An+B to the buffer.
+// See http://dev.w3.org/csswg/css-syntax/#serializing-anb
+func writeAnPlusB(b *bytes.Buffer, a, n int) {
+	switch a {
+	case 0:
+		b.WriteString(strconv.Itoa(n))
+	case 1:
+		b.WriteByte('n')
+	case -1:
+		b.WriteString("-n")
+	default:
+		b.WriteString(strconv.Itoa(a))
+		b.WriteByte('n')
+	}
+
+	if a != 0 {
+		if n > 0 {
+			b.WriteByte('+')
+			b.WriteString(strconv.Itoa(n))
+		} else if n < 0 {
+			b.WriteString(strconv.Itoa(n))
+		}
+	}
+}
+
+// Parse an An+B notation from the tokens of Tokenizer t up to its end.
 // Returns the value for A and B on successful parse.
 func parseNth(t Tokenizer) (int, int, error) {
 	var a, b int
 	var ok bool
 
-	err := fmt.Errorf("Invalid nth arguments at position %v", t.Position())
+	start := t.Position()
+	invalid := func() error {
+		return &SyntaxError{Msg: "Invalid nth arguments", Start: start, End: t.Position()}
+	}
 
 	tk := skipWhitespace(t)
 	switch tk.Type() {
 	case Number:
 		n := tk.(*NumberToken)
-		if !n.Integer || !closingParen(t) {
-			return 0, 0, err
+		if !n.Integer || !atEnd(t) {
+			return 0, 0, invalid()
 		}
 
-		if b, ok = parseInt(n.Value); ok {
-			return 0, b, nil
-		} else {
-			return 0, 0, err
-		}
+		return 0, int(n.Int), nil
 	case Dimension:
 		d := tk.(*DimensionToken)
 		if !d.Integer {
-			return 0, 0, err
-		}
-
-		a, ok = parseInt(d.Value)
-		if !ok {
-			return 0, 0, err
+			return 0, 0, invalid()
 		}
 
+		a = int(d.Int)
 		unit := strings.ToLower(d.Unit)
 		if unit == "n" {
 			b, ok = parseB(t)
@@ -49,11 +179,11 @@ func parseNth(t Tokenizer) (int, int, error) {
 			b, ok = parseSignlessB(t, -1)
 		} else {
 			b, ok = parseNDashDigits(unit)
-			ok = ok && closingParen(t)
+			ok = ok && atEnd(t)
 		}
 
 		if !ok {
-			return 0, 0, err
+			return 0, 0, invalid()
 		}
 
 		return a, b, nil
@@ -62,10 +192,10 @@ func parseNth(t Tokenizer) (int, int, error) {
 		switch ident {
 		case "even":
 			a, b = 2, 0
-			ok = closingParen(t)
+			ok = atEnd(t)
 		case "odd":
 			a, b = 2, 1
-			ok = closingParen(t)
+			ok = atEnd(t)
 		case "n":
 			a = 1
 			b, ok = parseB(t)
@@ -82,27 +212,27 @@ func parseNth(t Tokenizer) (int, int, error) {
 			if strings.HasPrefix(ident, "-") {
 				a = -1
 				b, ok = parseNDashDigits(ident[1:])
-				ok = ok && closingParen(t)
+				ok = ok && atEnd(t)
 			} else {
 				a = 1
 				b, ok = parseNDashDigits(ident)
-				ok = ok && closingParen(t)
+				ok = ok && atEnd(t)
 			}
 		}
 
 		if !ok {
-			return 0, 0, err
+			return 0, 0, invalid()
 		}
 
 		return a, b, nil
 	case Delim:
 		if tk.String() != "+" {
-			return 0, 0, err
+			return 0, 0, invalid()
 		}
 
 		tk = t.NextToken()
 		if tk.Type() != Ident {
-			return 0, 0, err
+			return 0, 0, invalid()
 		}
 
 		ident := strings.ToLower(tk.String())
@@ -116,23 +246,23 @@ func parseNth(t Tokenizer) (int, int, error) {
 		default:
 			a = 1
 			b, ok = parseNDashDigits(ident)
-			ok = ok && closingParen(t)
+			ok = ok && atEnd(t)
 		}
 
 		if !ok {
-			return 0, 0, err
+			return 0, 0, invalid()
 		}
 
 		return a, b, nil
 	default:
-		return 0, 0, err
+		return 0, 0, invalid()
 	}
 }
 
 func parseB(t Tokenizer) (int, bool) {
 	tk := skipWhitespace(t)
 	switch tk.Type() {
-	case RightParen:
+	case EOF:
 		return 0, true
 	case Delim:
 		switch tk.String() {
@@ -145,11 +275,11 @@ func parseB(t Tokenizer) (int, bool) {
 		}
 	case Number:
 		n := tk.(*NumberToken)
-		if !n.Integer || !hasSign(n.Value) || !closingParen(t) {
+		if !n.Integer || !n.Signed || !atEnd(t) {
 			return 0, false
 		}
 
-		return parseInt(n.Value)
+		return int(n.Int), true
 	default:
 		return 0, false
 	}
@@ -158,15 +288,11 @@ func parseB(t Tokenizer) (int, bool) {
 func parseSignlessB(t Tokenizer, sign int) (int, bool) {
 	tk := skipWhitespace(t)
 	n, ok := tk.(*NumberToken)
-	if !ok || !n.Integer || hasSign(n.Value) || !closingParen(t) {
+	if !ok || !n.Integer || n.Signed || !atEnd(t) {
 		return 0, false
 	}
 
-	if b, ok := parseInt(n.Value); ok {
-		return sign * b, true
-	}
-
-	return 0, false
+	return sign * int(n.Int), true
 }
 
 func parseNDashDigits(s string) (int, bool) {
@@ -177,21 +303,18 @@ func parseNDashDigits(s string) (int, bool) {
 	return 0, false
 }
 
-func hasSign(s string) bool {
-	c := s[0:1]
-	return c == "+" || c == "-"
-}
-
+// Out of range integers are clamped to the closest value in range, like the values of numeric tokens.
 func parseInt(s string) (int, bool) {
-	if n, err := strconv.ParseInt(s, 10, 0); err == nil {
-		return int(n), true
-	} else {
+	n, err := strconv.ParseInt(s, 10, 0)
+	if err != nil && err.(*strconv.NumError).Err != strconv.ErrRange {
 		return 0, false
 	}
+
+	return int(n), true
 }
 
-func closingParen(t Tokenizer) bool {
-	return skipWhitespace(t).Type() == RightParen
+func atEnd(t Tokenizer) bool {
+	return skipWhitespace(t).Type() == EOF
 }
 
 func skipWhitespace(t Tokenizer) Token {
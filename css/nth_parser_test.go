@@ -6,6 +6,7 @@ package css
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"testing"
 )
@@ -26,30 +27,120 @@ func TestNthParserSpecCompliance(t *testing.T) {
 	}
 
 	var args string
-	var tok Tokenizer
 	for i, v := range arr {
 		if i%2 == 0 {
 			args = v.(string)
-			tok = NewTokenizer(args + ")")
 		} else {
 			if v == nil {
-				_, _, err := parseNth(tok)
+				_, err := ParseAnPlusB(args)
 				if err == nil {
 					t.Errorf(`Expected error for nth arguments %q at index %v`, args, i)
 				}
 			} else {
 				n := v.([]interface{})
 				a1, b1 := n[0].(float64), n[1].(float64)
-				a2, b2, err := parseNth(tok)
+				x, err := ParseAnPlusB(args)
 				if err != nil {
 					t.Errorf(`Error parsing nth arguments %q at index %v`, args, i)
 					continue
 				}
 
-				if float64(a2) != a1 || float64(b2) != b1 {
-					t.Errorf(`Value mismatch at index %v: [%v %v] != [%v %v]`, i, a2, b2, a1, b1)
+				if float64(x.A) != a1 || float64(x.B) != b1 {
+					t.Errorf(`Value mismatch at index %v: [%v %v] != [%v %v]`, i, x.A, x.B, a1, b1)
 				}
 			}
 		}
 	}
 }
+
+func TestParseAnPlusB(t *testing.T) {
+	tests := []struct {
+		input, output string
+		of            int
+	}{
+		{"odd", "2n+1", 0},
+		{" -n + 3 ", "-n+3", 0},
+		{"2n-0", "2n", 0},
+		{"+5", "5", 0},
+		{"2n+1 of .a", "2n+1 of .a", 1},
+		{"even OF p.a, div > span", "2n of p.a, div > span", 2},
+		{"-n+3 of :is(a, b)", "-n+3 of :is(a, b)", 1},
+	}
+
+	for _, test := range tests {
+		x, err := ParseAnPlusB(test.input)
+		if err != nil {
+			t.Errorf(`Error parsing %q: %s`, test.input, err)
+			continue
+		}
+
+		if s := x.String(); s != test.output {
+			t.Errorf(`Serialization of %q is %q, expected %q`, test.input, s, test.output)
+		}
+
+		if len(x.Of) != test.of {
+			t.Errorf(`Expected %d selectors in the of clause of %q, got %d`, test.of, test.input, len(x.Of))
+		}
+	}
+
+	for _, input := range []string{"2n of", "of .a", "2n+1 .a", "2n of ::before", "2n of .a,"} {
+		if _, err := ParseAnPlusB(input); err == nil {
+			t.Errorf(`Expected error for %q`, input)
+		} else if _, ok := err.(*SyntaxError); !ok {
+			t.Errorf(`Expected a *SyntaxError for %q, got %T`, input, err)
+		}
+	}
+}
+
+func TestAnPlusBMatches(t *testing.T) {
+	tests := []struct {
+		input   string
+		indices []int
+	}{
+		{"0", nil},
+		{"3", []int{3}},
+		{"n", []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{"odd", []int{1, 3, 5, 7, 9}},
+		{"even", []int{2, 4, 6, 8, 10}},
+		{"3n-2", []int{1, 4, 7, 10}},
+		{"-n+3", []int{1, 2, 3}},
+		{"-2n+7", []int{1, 3, 5, 7}},
+		{"n+8", []int{8, 9, 10}},
+		{"-n", nil},
+		{"-3n+2", []int{2}},
+		{"-3n+8", []int{2, 5, 8}},
+		{"-4n-1", nil},
+		{"5n-12", []int{3, 8}},
+		{"-0n+4", []int{4}},
+		{"-n-99999999999999999999", nil},
+		{"n-99999999999999999999", []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{"2n-99999999999999999999", []int{2, 4, 6, 8, 10}},
+		{"-n+99999999999999999999", []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{"-2n+99999999999999999999", []int{1, 3, 5, 7, 9}},
+		{"99999999999999999999n+1", []int{1}},
+		{"-99999999999999999999n+10", []int{10}},
+		{"99999999999999999999n-99999999999999999999", nil},
+		{"-99999999999999999999n-99999999999999999999", nil},
+		{"99999999999999999999", nil},
+		{"-99999999999999999999", nil},
+	}
+
+	for _, test := range tests {
+		x, err := ParseAnPlusB(test.input)
+		if err != nil {
+			t.Errorf(`Error parsing %q: %s`, test.input, err)
+			continue
+		}
+
+		var indices []int
+		for i := 1; i <= 10; i++ {
+			if x.Matches(i) {
+				indices = append(indices, i)
+			}
+		}
+
+		if fmt.Sprint(indices) != fmt.Sprint(test.indices) {
+			t.Errorf(`Indices matching %q are %v, expected %v`, test.input, indices, test.indices)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by the parse functions that expect a single construct.
+var (
+	ErrEmptyInput   = errors.New("Empty input")
+	ErrInvalidInput = errors.New("Invalid input")
+	ErrExtraInput   = errors.New("Extra input")
+)
+
+// Represents a syntax error found when parsing, e.g. a selector.
+type SyntaxError struct {
+	Msg      string   // The description of this error.
+	Start    Pos      // The start of the offending input.
+	End      Pos      // The end of the offending input.
+	Token    Token    // The offending token, nil if the error doesn't concern a single token.
+	Expected []string // What was expected instead of the offending token, empty if not known.
+
+	unforgiving bool // If the selector having this error isn't dropped from a forgiving selector list.
+}
+
+func (e *SyntaxError) Error() string {
+	s := fmt.Sprintf("%s at line %d, column %d", e.Msg, e.Start.Line, e.Start.Column)
+	if len(e.Expected) > 0 && e.Token != nil {
+		s += ", got " + describeToken(e.Token)
+	}
+
+	return s
+}
+
+// Returns a description of tk to use in error messages.
+func describeToken(tk Token) string {
+	switch tk.Type() {
+	case EOF:
+		return "EOF"
+	case Whitespace:
+		return "whitespace"
+	}
+
+	return tk.String()
+}
+
+// Parse a style sheet from Tokenizer t.
+// See http://www.w3.org/TR/css-syntax-3/#parse-a-stylesheet
+func ParseStylesheet(t Tokenizer) *Stylesheet {
+	s, _ := ParseStylesheetWithErrors(t)
+	return s
+}
+
+// Parse a style sheet from Tokenizer t, also returning the errors of the invalid rules
+// that were dropped from the style sheet, in the order they were found.
+func ParseStylesheetWithErrors(t Tokenizer) (*Stylesheet, []*SyntaxError) {
+	p := &parser{tokenizer: withoutComments(t)}
+	return &Stylesheet{Rules: p.consumeRuleList(true)}, p.errors
+}
+
+// Parse a style sheet from the string s.
+func ParseStylesheetFromString(s string) *Stylesheet {
+	return ParseStylesheet(NewTokenizer(s))
+}
+
+// Parse a list of rules from Tokenizer t.
+// See http://www.w3.org/TR/css-syntax-3/#parse-a-list-of-rules
+func ParseRuleList(t Tokenizer) []Rule {
+	r, _ := ParseRuleListWithErrors(t)
+	return r
+}
+
+// Parse a list of rules from Tokenizer t, also returning the errors of the invalid rules
+// that were dropped from the list, in the order they were found.
+func ParseRuleListWithErrors(t Tokenizer) ([]Rule, []*SyntaxError) {
+	p := &parser{tokenizer: withoutComments(t)}
+	return p.consumeRuleList(false), p.errors
+}
+
+// Parse a single rule from Tokenizer t.
+// See http://www.w3.org/TR/css-syntax-3/#parse-a-rule
+func ParseRule(t Tokenizer) (Rule, error) {
+	p := &parser{tokenizer: withoutComments(t)}
+	tk := p.skipWhitespace()
+	var r Rule
+	switch tk.Type() {
+	case EOF:
+		return nil, ErrEmptyInput
+	case AtKeyword:
+		r = p.consumeAtRule(tk)
+	default:
+		p.reconsume(tk)
+		if q := p.consumeQualifiedRule(); q != nil {
+			r = q
+		} else {
+			return nil, ErrInvalidInput
+		}
+	}
+
+	if p.skipWhitespace().Type() != EOF {
+		return nil, ErrExtraInput
+	}
+
+	return r, nil
+}
+
+// Parse a single declaration from Tokenizer t.
+// See http://www.w3.org/TR/css-syntax-3/#parse-a-declaration
+func ParseDeclaration(t Tokenizer) (*Declaration, error) {
+	p := &parser{tokenizer: withoutComments(t)}
+	tk := p.skipWhitespace()
+	switch tk.Type() {
+	case EOF:
+		return nil, ErrEmptyInput
+	case Ident:
+	default:
+		return nil, ErrInvalidInput
+	}
+
+	values := []ComponentValue{tk}
+	for {
+		tk = p.next()
+		if tk.Type() == EOF {
+			break
+		}
+
+		values = append(values, p.consumeComponentValue(tk))
+	}
+
+	if d := consumeDeclaration(values); d != nil {
+		return d, nil
+	}
+
+	return nil, ErrInvalidInput
+}
+
+// Parse a list of declarations from Tokenizer t.
+// See http://www.w3.org/TR/css-syntax-3/#parse-a-list-of-declarations
+func ParseDeclarationList(t Tokenizer) []DeclarationListItem {
+	d, _ := ParseDeclarationListWithErrors(t)
+	return d
+}
+
+// Parse a list of declarations from Tokenizer t, also returning the errors of the invalid
+// declarations that were dropped from the list, in the order they were found.
+func ParseDeclarationListWithErrors(t Tokenizer) ([]DeclarationListItem, []*SyntaxError) {
+	p := &parser{tokenizer: withoutComments(t)}
+	return p.consumeDeclarationList(), p.errors
+}
+
+// Parse a single component value from Tokenizer t.
+// See http://www.w3.org/TR/css-syntax-3/#parse-a-component-value
+func ParseComponentValue(t Tokenizer) (ComponentValue, error) {
+	p := &parser{tokenizer: withoutComments(t)}
+	tk := p.skipWhitespace()
+	if tk.Type() == EOF {
+		return nil, ErrEmptyInput
+	}
+
+	v := p.consumeComponentValue(tk)
+	if p.skipWhitespace().Type() != EOF {
+		return nil, ErrExtraInput
+	}
+
+	return v, nil
+}
+
+// Parse a list of component values from Tokenizer t.
+// See http://www.w3.org/TR/css-syntax-3/#parse-a-list-of-component-values
+func ParseComponentValues(t Tokenizer) []ComponentValue {
+	p := &parser{tokenizer: withoutComments(t)}
+
+	var values []ComponentValue
+	for {
+		tk := p.next()
+		if tk.Type() == EOF {
+			return values
+		}
+
+		values = append(values, p.consumeComponentValue(tk))
+	}
+}
+
+// Parser state.
+type parser struct {
+	tokenizer Tokenizer      // Tokenizer used when parsing.
+	saved     Token          // Possibly saved token.
+	errors    []*SyntaxError // The errors of the invalid rules and declarations that were dropped.
+}
+
+// Records the error of an invalid rule or declaration starting at start that was dropped.
+func (p *parser) invalid(msg string, start Pos) {
+	p.errors = append(p.errors, &SyntaxError{Msg: msg, Start: start, End: p.tokenizer.Position()})
+}
+
+// Returns the next token to parse.
+func (p *parser) next() Token {
+	if p.saved != nil {
+		tk := p.saved
+		p.saved = nil
+		return tk
+	}
+
+	return p.tokenizer.NextToken()
+}
+
+// Pushes back tk so that it is returned by the next call to next.
+func (p *parser) reconsume(tk Token) {
+	p.saved = tk
+}
+
+// Skips whitespace tokens and returns the next non-whitespace token.
+func (p *parser) skipWhitespace() Token {
+	for {
+		tk := p.next()
+		if tk.Type() != Whitespace {
+			return tk
+		}
+	}
+}
+
+// Consume a list of rules.
+// See http://www.w3.org/TR/css-syntax-3/#consume-a-list-of-rules
+func (p *parser) consumeRuleList(topLevel bool) []Rule {
+	var rules []Rule
+	for {
+		tk := p.next()
+		switch tk.Type() {
+		case Whitespace:
+		case EOF:
+			return rules
+		case CDO, CDC:
+			if topLevel {
+				continue
+			}
+
+			p.reconsume(tk)
+			if r := p.consumeQualifiedRule(); r != nil {
+				rules = append(rules, r)
+			} else {
+				p.invalid("Invalid qualified rule", tk.Position())
+			}
+		case AtKeyword:
+			rules = append(rules, p.consumeAtRule(tk))
+		default:
+			p.reconsume(tk)
+			if r := p.consumeQualifiedRule(); r != nil {
+				rules = append(rules, r)
+			} else {
+				p.invalid("Invalid qualified rule", tk.Position())
+			}
+		}
+	}
+}
+
+// Consume an at-rule.
+// It is assumed that tk is the at-keyword token that has just been consumed.
+// See http://www.w3.org/TR/css-syntax-3/#consume-an-at-rule
+func (p *parser) consumeAtRule(tk Token) *AtRule {
+	r := &AtRule{
+		Pos:  tk.Position(),
+		Name: tk.String(),
+	}
+
+	for {
+		tk = p.next()
+		switch tk.Type() {
+		case Semicolon, EOF:
+			return r
+		case LeftCurlyBracket:
+			r.Block = p.consumeSimpleBlock(tk)
+			return r
+		default:
+			r.Prelude = append(r.Prelude, p.consumeComponentValue(tk))
+		}
+	}
+}
+
+// Consume a qualified rule.
+// Returns nil if EOF was reached before a block.
+// See http://www.w3.org/TR/css-syntax-3/#consume-a-qualified-rule
+func (p *parser) consumeQualifiedRule() *QualifiedRule {
+	tk := p.next()
+	r := &QualifiedRule{Pos: tk.Position()}
+	for ; ; tk = p.next() {
+		switch tk.Type() {
+		case EOF:
+			return nil
+		case LeftCurlyBracket:
+			r.Block = p.consumeSimpleBlock(tk)
+			return r
+		default:
+			r.Prelude = append(r.Prelude, p.consumeComponentValue(tk))
+		}
+	}
+}
+
+// Consume a list of declarations.
+// See http://www.w3.org/TR/css-syntax-3/#consume-a-list-of-declarations
+func (p *parser) consumeDeclarationList() []DeclarationListItem {
+	var items []DeclarationListItem
+	for {
+		tk := p.next()
+		switch tk.Type() {
+		case Whitespace, Semicolon:
+		case EOF:
+			return items
+		case AtKeyword:
+			items = append(items, p.consumeAtRule(tk))
+		case Ident:
+			values := []ComponentValue{tk}
+			values = append(values, p.consumeUntilSemicolon()...)
+			if d := consumeDeclaration(values); d != nil {
+				items = append(items, d)
+			} else {
+				p.invalid("Invalid declaration", tk.Position())
+			}
+		default:
+			p.reconsume(tk)
+			p.consumeUntilSemicolon()
+			p.invalid("Invalid declaration", tk.Position())
+		}
+	}
+}
+
+// Consumes component values until a semicolon token or EOF is found.
+// The semicolon or EOF is left to be consumed by the caller.
+func (p *parser) consumeUntilSemicolon() []ComponentValue {
+	var values []ComponentValue
+	for {
+		tk := p.next()
+		switch tk.Type() {
+		case Semicolon, EOF:
+			p.reconsume(tk)
+			return values
+		}
+
+		values = append(values, p.consumeComponentValue(tk))
+	}
+}
+
+// Consume a component value.
+// It is assumed that tk is the token that has just been consumed.
+// See http://www.w3.org/TR/css-syntax-3/#consume-a-component-value
+func (p *parser) consumeComponentValue(tk Token) ComponentValue {
+	switch tk.Type() {
+	case LeftCurlyBracket, LeftSquareBracket, LeftParen:
+		return p.consumeSimpleBlock(tk)
+	case Function:
+		return p.consumeFunction(tk)
+	default:
+		return tk
+	}
+}
+
+// Consume a simple block.
+// It is assumed that tk is the token opening the block that has just been consumed.
+// See http://www.w3.org/TR/css-syntax-3/#consume-a-simple-block
+func (p *parser) consumeSimpleBlock(tk Token) *SimpleBlock {
+	b := &SimpleBlock{
+		Pos:        tk.Position(),
+		Associated: tk.Type(),
+	}
+
+	end := mirror(tk.Type())
+	for {
+		tk = p.next()
+		switch tk.Type() {
+		case end, EOF:
+			return b
+		default:
+			b.Value = append(b.Value, p.consumeComponentValue(tk))
+		}
+	}
+}
+
+// Consume a function.
+// It is assumed that tk is the function token that has just been consumed.
+// See http://www.w3.org/TR/css-syntax-3/#consume-a-function
+func (p *parser) consumeFunction(tk Token) *FunctionValue {
+	f := &FunctionValue{
+		Pos:  tk.Position(),
+		Name: tk.String(),
+	}
+
+	for {
+		tk = p.next()
+		switch tk.Type() {
+		case RightParen, EOF:
+			return f
+		default:
+			f.Value = append(f.Value, p.consumeComponentValue(tk))
+		}
+	}
+}
+
+// Consume a declaration from a list of component values starting with an ident token.
+// Returns nil if the values doesn't represent a valid declaration.
+// See http://www.w3.org/TR/css-syntax-3/#consume-a-declaration
+func consumeDeclaration(values []ComponentValue) *Declaration {
+	name := values[0].(Token)
+	d := &Declaration{
+		Pos:  name.Position(),
+		Name: name.String(),
+	}
+
+	values = trimWhitespace(values[1:])
+	if len(values) == 0 || !isToken(values[0], Colon) {
+		return nil
+	}
+
+	values = trimWhitespace(values[1:])
+	if n := len(values); n >= 2 && isToken(values[n-1], Ident) && strings.EqualFold(values[n-1].(Token).String(), "important") {
+		i := trimWhitespace(values[:n-1])
+		if m := len(i); m > 0 && isToken(i[m-1], Delim) && i[m-1].(Token).String() == "!" {
+			d.Important = true
+			values = trimWhitespace(i[:m-1])
+		}
+	}
+
+	d.Value = values
+	return d
+}
+
+// Returns values without any leading or trailing whitespace tokens.
+func trimWhitespace(values []ComponentValue) []ComponentValue {
+	for len(values) > 0 && isToken(values[0], Whitespace) {
+		values = values[1:]
+	}
+
+	for len(values) > 0 && isToken(values[len(values)-1], Whitespace) {
+		values = values[:len(values)-1]
+	}
+
+	return values
+}
+
+// Returns whether v is a token of the given type.
+func isToken(v ComponentValue, typ TokenType) bool {
+	tk, ok := v.(Token)
+	return ok && tk.Type() == typ
+}
+
+// Returns the token type closing a block opened by a token of the given type.
+func mirror(typ TokenType) TokenType {
+	switch typ {
+	case LeftCurlyBracket:
+		return RightCurlyBracket
+	case LeftSquareBracket:
+		return RightSquareBracket
+	default:
+		return RightParen
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestParseComponentValue(t *testing.T) {
+	runParserTests(t, "one_component_value.json", func(tk Tokenizer) interface{} {
+		v, err := ParseComponentValue(tk)
+		if err != nil {
+			return errorValue(err)
+		}
+
+		return componentValue(v, t)
+	})
+}
+
+func TestParseDeclaration(t *testing.T) {
+	runParserTests(t, "one_declaration.json", func(tk Tokenizer) interface{} {
+		d, err := ParseDeclaration(tk)
+		if err != nil {
+			return errorValue(err)
+		}
+
+		return ruleValue(d, t)
+	})
+}
+
+func TestParseRule(t *testing.T) {
+	runParserTests(t, "one_rule.json", func(tk Tokenizer) interface{} {
+		r, err := ParseRule(tk)
+		if err != nil {
+			return errorValue(err)
+		}
+
+		return ruleValue(r, t)
+	})
+}
+
+func TestParseRuleList(t *testing.T) {
+	runParserTests(t, "rule_list.json", func(tk Tokenizer) interface{} {
+		rules, errs := ParseRuleListWithErrors(tk)
+		var items []positioned
+		for _, x := range rules {
+			items = append(items, x)
+		}
+
+		return listValue(items, errs, t)
+	})
+}
+
+func TestParseStylesheet(t *testing.T) {
+	runParserTests(t, "stylesheet.json", func(tk Tokenizer) interface{} {
+		s, errs := ParseStylesheetWithErrors(tk)
+		var items []positioned
+		for _, x := range s.Rules {
+			items = append(items, x)
+		}
+
+		return listValue(items, errs, t)
+	})
+}
+
+func TestParseDeclarationList(t *testing.T) {
+	runParserTests(t, "declaration_list.json", func(tk Tokenizer) interface{} {
+		declarations, errs := ParseDeclarationListWithErrors(tk)
+		var items []positioned
+		for _, x := range declarations {
+			items = append(items, x)
+		}
+
+		return listValue(items, errs, t)
+	})
+}
+
+// Runs the css-parsing-tests in the given file using the parse function f.
+func runParserTests(t *testing.T, file string, f func(Tokenizer) interface{}) {
+	data, err := ioutil.ReadFile("testdata/" + file)
+	if err != nil {
+		t.Fatalf("Could not read %s", file)
+	}
+
+	var arr []interface{}
+	if err := json.Unmarshal(data, &arr); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(arr)%2 != 0 {
+		t.Fatalf("%s is invalid", file)
+	}
+
+	for i := 0; i < len(arr); i += 2 {
+		input := arr[i].(string)
+		e := arr[i+1]
+		r := f(NewTokenizer(input))
+		if !reflect.DeepEqual(r, e) {
+			t.Errorf("Value mismatch in %s for %q:\n%#v != %#v", file, input, r, e)
+		}
+	}
+}
+
+// Represents a rule or an item of a declaration list.
+type positioned interface {
+	Position() Pos
+}
+
+// Returns the values of the items of a list with an ["error", "invalid"] entry in place of
+// each of the invalid rules or declarations that the parser reported and dropped.
+func listValue(items []positioned, errs []*SyntaxError, t *testing.T) []interface{} {
+	r := []interface{}{}
+	for len(items) > 0 || len(errs) > 0 {
+		if len(errs) > 0 && (len(items) == 0 || errs[0].Start.Offset < items[0].Position().Offset) {
+			r = append(r, vals("error", "invalid"))
+			errs = errs[1:]
+		} else {
+			r = append(r, ruleValue(items[0], t))
+			items = items[1:]
+		}
+	}
+
+	return r
+}
+
+func errorValue(err error) interface{} {
+	switch err {
+	case ErrEmptyInput:
+		return vals("error", "empty")
+	case ErrInvalidInput:
+		return vals("error", "invalid")
+	case ErrExtraInput:
+		return vals("error", "extra-input")
+	default:
+		return vals("error", err.Error())
+	}
+}
+
+func ruleValue(v interface{}, t *testing.T) interface{} {
+	switch x := v.(type) {
+	case *AtRule:
+		var b interface{}
+		if x.Block != nil {
+			b = componentValues(x.Block.Value, t)
+		}
+
+		return vals("at-rule", x.Name, componentValues(x.Prelude, t), b)
+	case *QualifiedRule:
+		return vals("qualified rule", componentValues(x.Prelude, t), componentValues(x.Block.Value, t))
+	case *Declaration:
+		return vals("declaration", x.Name, componentValues(x.Value, t), x.Important)
+	default:
+		t.Fatalf("Unexpected rule %#v", v)
+		return nil
+	}
+}
+
+func componentValues(v []ComponentValue, t *testing.T) []interface{} {
+	r := []interface{}{}
+	for _, x := range v {
+		r = append(r, componentValue(x, t))
+	}
+
+	return r
+}
+
+func componentValue(v ComponentValue, t *testing.T) interface{} {
+	switch x := v.(type) {
+	case *FunctionValue:
+		return append(vals("function", x.Name), componentValues(x.Value, t)...)
+	case *SimpleBlock:
+		var s string
+		switch x.Associated {
+		case LeftCurlyBracket:
+			s = "{}"
+		case LeftSquareBracket:
+			s = "[]"
+		default:
+			s = "()"
+		}
+
+		return append(vals(s), componentValues(x.Value, t)...)
+	case Token:
+		switch x.Type() {
+		case RightCurlyBracket, RightSquareBracket, RightParen:
+			return vals("error", x.String())
+		}
+
+		return tokenValue(x, t)
+	default:
+		t.Fatalf("Unexpected component value %#v", v)
+		return nil
+	}
+}
+
+func TestParseListErrors(t *testing.T) {
+	_, errs := ParseDeclarationListWithErrors(NewTokenizer("a: b; 12; c\n;d:e"))
+	want := []string{"Invalid declaration at line 1, column 7", "Invalid declaration at line 1, column 11"}
+	if len(errs) != len(want) {
+		t.Fatalf(`Got %d declaration list errors, want %d`, len(errs), len(want))
+	}
+
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf(`Got error %q, want %q`, err, want[i])
+		}
+	}
+
+	_, errs = ParseRuleListWithErrors(NewTokenizer("a {}\n  b c"))
+	if len(errs) != 1 || errs[0].Error() != "Invalid qualified rule at line 2, column 3" {
+		t.Errorf(`Got rule list errors %v, want one invalid qualified rule at line 2, column 3`, errs)
+	}
+
+	if s, errs := ParseStylesheetWithErrors(NewTokenizer("<!-- a {} -->")); len(s.Rules) != 1 || len(errs) != 0 {
+		t.Errorf(`Got %d rules and %d errors parsing a style sheet with CDO and CDC, want 1 and 0`, len(s.Rules), len(errs))
+	}
+}
@@ -6,6 +6,17 @@ package css
 
 import "golang.org/x/net/html"
 
+// Returns the first node within n, in depth-first pre-order, that matches the given selectors string.
+// Returns nil if no node matches.
+func QuerySelector(selectors string, n *html.Node) (*html.Node, error) {
+	s, err := ParseSelectorFromString(selectors)
+	if err != nil {
+		return nil, err
+	}
+
+	return Query(s, n), nil
+}
+
 // Returns all the nodes within n that match the given selectors string.
 func QuerySelectorAll(selectors string, n *html.Node) ([]*html.Node, error) {
 	s, err := ParseSelectorFromString(selectors)
@@ -16,11 +27,51 @@ func QuerySelectorAll(selectors string, n *html.Node) ([]*html.Node, error) {
 	return QueryAll(s, n), nil
 }
 
+// Returns the first node within n, in depth-first pre-order, that matches the given selectors string
+// parsed using the given options. Returns nil if no node matches.
+func QuerySelectorWithOptions(selectors string, n *html.Node, o ParserOptions) (*html.Node, error) {
+	s, err := ParseSelectorWithOptions(NewTokenizer(selectors), o)
+	if err != nil {
+		return nil, err
+	}
+
+	return Query(s, n), nil
+}
+
+// Returns all the nodes within n that match the given selectors string parsed using the given options.
+func QuerySelectorAllWithOptions(selectors string, n *html.Node, o ParserOptions) ([]*html.Node, error) {
+	s, err := ParseSelectorWithOptions(NewTokenizer(selectors), o)
+	if err != nil {
+		return nil, err
+	}
+
+	return QueryAll(s, n), nil
+}
+
+// Returns the first node within n, in depth-first pre-order, that matches the given SelectorsGroup.
+// Returns nil if no node matches. The :scope pseudo class matches n, or the root element if n is a document node.
+func Query(s SelectorsGroup, n *html.Node) *html.Node {
+	m := &matchState{scope: htmlElementOrNil(n)}
+	if hasPositional(s) {
+		return firstNode(m.queryPositional(s, htmlElements(n)))
+	}
+
+	return find(n, func(x *html.Node) bool {
+		return m.matchesSelectors(s, HTMLElement{x})
+	})
+}
+
 // Returns all the nodes within n that match the given SelectorsGroup.
+// The :scope pseudo class matches n, or the root element if n is a document node.
 func QueryAll(s SelectorsGroup, n *html.Node) []*html.Node {
+	m := &matchState{scope: htmlElementOrNil(n)}
+	if hasPositional(s) {
+		return nodes(m.queryPositional(s, htmlElements(n)))
+	}
+
 	var result []*html.Node
 	Traverse(n, func(x *html.Node) {
-		if MatchesSelectors(s, x, nil) {
+		if m.matchesSelectors(s, HTMLElement{x}) {
 			result = append(result, x)
 		}
 	})
@@ -28,6 +79,89 @@ func QueryAll(s SelectorsGroup, n *html.Node) []*html.Node {
 	return result
 }
 
+// Returns the first element within e, including e itself, in depth-first pre-order,
+// that matches the given SelectorsGroup. Returns nil if no element matches.
+// The :scope pseudo class matches e.
+func QueryElement(s SelectorsGroup, e Element) Element {
+	m := &matchState{scope: e}
+	if hasPositional(s) {
+		if r := m.queryPositional(s, elementsWithin(e)); len(r) > 0 {
+			return r[0]
+		}
+
+		return nil
+	}
+
+	return findElement(e, func(x Element) bool {
+		return m.matchesSelectors(s, x)
+	})
+}
+
+// Returns all the elements within e, including e itself, that match the given SelectorsGroup.
+// The :scope pseudo class matches e.
+func QueryAllElements(s SelectorsGroup, e Element) []Element {
+	m := &matchState{scope: e}
+	if hasPositional(s) {
+		return m.queryPositional(s, elementsWithin(e))
+	}
+
+	var result []Element
+	TraverseElements(e, func(x Element) {
+		if m.matchesSelectors(s, x) {
+			result = append(result, x)
+		}
+	})
+
+	return result
+}
+
+// Returns whether the node n matches the given selectors string.
+// The root element is matched if n is a document node. The :scope pseudo class matches n itself.
+func Matches(selectors string, n *html.Node) (bool, error) {
+	s, err := ParseSelectorFromString(selectors)
+	if err != nil {
+		return false, err
+	}
+
+	e := htmlElement(n)
+	if e == nil {
+		return false, nil
+	}
+
+	m := &matchState{scope: e}
+	return m.matchesSelectors(s, e), nil
+}
+
+// Returns the closest node, starting with n itself and walking up its ancestors,
+// that matches the given selectors string. Returns nil if no node matches.
+// The :scope pseudo class matches n itself.
+func Closest(selectors string, n *html.Node) (*html.Node, error) {
+	s, err := ParseSelectorFromString(selectors)
+	if err != nil {
+		return nil, err
+	}
+
+	if e := ClosestElement(s, htmlElementOrNil(n)); e != nil {
+		return e.(HTMLElement).Node, nil
+	}
+
+	return nil, nil
+}
+
+// Returns the closest element, starting with e itself and walking up its ancestors,
+// that matches the given SelectorsGroup. Returns nil if no element matches or if e is nil.
+// The :scope pseudo class matches e itself.
+func ClosestElement(s SelectorsGroup, e Element) Element {
+	m := &matchState{scope: e}
+	for x := e; x != nil; x = x.Parent() {
+		if m.matchesSelectors(s, x) {
+			return x
+		}
+	}
+
+	return nil
+}
+
 // Traverses the nodes within n using depth-first pre-order traversal.
 func Traverse(n *html.Node, f func(*html.Node)) {
 	if n.Type == html.ElementNode {
@@ -38,3 +172,82 @@ func Traverse(n *html.Node, f func(*html.Node)) {
 		Traverse(c, f)
 	}
 }
+
+// Traverses e and the elements within it using depth-first pre-order traversal.
+func TraverseElements(e Element, f func(Element)) {
+	f(e)
+	for c := e.FirstChild(); c != nil; c = c.NextSibling() {
+		TraverseElements(c, f)
+	}
+}
+
+// Returns the first node within n, in depth-first pre-order, for which f returns true.
+// The traversal stops at that node.
+func find(n *html.Node, f func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && f(n) {
+		return n
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if x := find(c, f); x != nil {
+			return x
+		}
+	}
+
+	return nil
+}
+
+// Returns the first element within e, including e itself, in depth-first pre-order, for which f returns true.
+// The traversal stops at that element.
+func findElement(e Element, f func(Element) bool) Element {
+	if f(e) {
+		return e
+	}
+
+	for c := e.FirstChild(); c != nil; c = c.NextSibling() {
+		if x := findElement(c, f); x != nil {
+			return x
+		}
+	}
+
+	return nil
+}
+
+// Returns the elements within n in depth-first pre-order.
+func htmlElements(n *html.Node) []Element {
+	var r []Element
+	Traverse(n, func(x *html.Node) {
+		r = append(r, HTMLElement{x})
+	})
+
+	return r
+}
+
+// Returns e and the elements within it in depth-first pre-order.
+func elementsWithin(e Element) []Element {
+	var r []Element
+	TraverseElements(e, func(x Element) {
+		r = append(r, x)
+	})
+
+	return r
+}
+
+// Returns the HTML nodes of the given HTML elements.
+func nodes(elements []Element) []*html.Node {
+	var r []*html.Node
+	for _, e := range elements {
+		r = append(r, e.(HTMLElement).Node)
+	}
+
+	return r
+}
+
+// Returns the HTML node of the first of the given HTML elements, nil if none.
+func firstNode(elements []Element) *html.Node {
+	if len(elements) == 0 {
+		return nil
+	}
+
+	return elements[0].(HTMLElement).Node
+}
@@ -0,0 +1,177 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const queryHTML = `<!DOCTYPE html>
+<html><body>
+<ul id="outer">
+	<li id="a">a<ul id="inner"><li id="b">b</li><li id="c">c</li></ul></li>
+	<li id="d">d</li>
+</ul>
+</body></html>`
+
+func parseQueryHTML(t *testing.T) *html.Node {
+	doc, err := html.Parse(strings.NewReader(queryHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return doc
+}
+
+func nodeID(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+
+	for _, a := range n.Attr {
+		if a.Key == "id" {
+			return a.Val
+		}
+	}
+
+	return n.Data
+}
+
+// Returns the ids of the given nodes, or their names if they have no id, separated by spaces.
+func nodeIDs(nodes []*html.Node) string {
+	var ids []string
+	for _, n := range nodes {
+		ids = append(ids, nodeID(n))
+	}
+
+	return strings.Join(ids, " ")
+}
+
+func TestQuerySelector(t *testing.T) {
+	doc := parseQueryHTML(t)
+	tests := map[string]string{
+		`li`:          "a",
+		`li li`:       "b",
+		`li + li`:     "c",
+		`#inner ~ li`: "",
+		`ul`:          "outer",
+		`:scope`:      "html",
+		`p`:           "",
+	}
+
+	for k, v := range tests {
+		n, err := QuerySelector(k, doc)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+		} else if id := nodeID(n); id != v {
+			t.Errorf(`Got %q as the first node matching %q, want %q`, id, k, v)
+		}
+
+		s, _ := ParseSelectorFromString(k)
+		m, _ := Compile(s)
+		if id := nodeID(m.Query(doc)); id != v {
+			t.Errorf(`Got %q as the first node matching compiled %q, want %q`, id, k, v)
+		}
+	}
+
+	if _, err := QuerySelector(`li[`, doc); err == nil {
+		t.Errorf(`Expected error parsing "li["`)
+	}
+}
+
+func TestScopedQueries(t *testing.T) {
+	doc := parseQueryHTML(t)
+	outer, _ := QuerySelector(`#outer`, doc)
+	tests := map[string]string{
+		`:scope > li`:         "a d",
+		`:scope li`:           "a b c d",
+		`:scope > li > ul`:    "inner",
+		`:scope`:              "outer",
+		`:not(:scope)`:        "a inner b c d",
+		`li:has(:scope)`:      "",
+		`:scope > li:has(li)`: "a",
+	}
+
+	for k, v := range tests {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		m, _ := Compile(s)
+		for i, r := range [][]*html.Node{QueryAll(s, outer), m.QueryAll(outer)} {
+			if x := nodeIDs(r); x != v {
+				t.Errorf(`Got %q matching %q within #outer (%d), want %q`, x, k, i, v)
+			}
+		}
+
+		if x := nodeIDs(nodes(QueryAllElements(s, HTMLElement{outer}))); x != v {
+			t.Errorf(`Got %q elements matching %q within #outer, want %q`, x, k, v)
+		}
+	}
+
+	if r, _ := QuerySelectorAll(`:scope > body`, doc); len(r) != 1 {
+		t.Errorf(`Got %d nodes matching ":scope > body" within the document, want 1`, len(r))
+	}
+}
+
+func TestMatches(t *testing.T) {
+	doc := parseQueryHTML(t)
+	b, _ := QuerySelector(`#b`, doc)
+	tests := map[string]bool{
+		`li`:             true,
+		`#outer li`:      true,
+		`#outer > li`:    false,
+		`:scope`:         true,
+		`ul > :scope`:    true,
+		`:first-child`:   true,
+		`:last-child`:    false,
+		`li:not(:scope)`: false,
+	}
+
+	for k, v := range tests {
+		if r, err := Matches(k, b); err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+		} else if r != v {
+			t.Errorf(`Got %v matching %q against #b, want %v`, r, k, v)
+		}
+	}
+
+	if r, _ := Matches(`html`, doc); !r {
+		t.Errorf(`Expected the document node to match "html"`)
+	}
+}
+
+func TestClosest(t *testing.T) {
+	doc := parseQueryHTML(t)
+	b, _ := QuerySelector(`#b`, doc)
+	tests := map[string]string{
+		`li`:           "b",
+		`ul`:           "inner",
+		`li li`:        "b",
+		`:not(:scope)`: "inner",
+		`#outer > li`:  "a",
+		`body > *`:     "outer",
+		`:root`:        "html",
+		`p`:            "",
+		`:scope`:       "b",
+	}
+
+	for k, v := range tests {
+		if n, err := Closest(k, b); err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+		} else if id := nodeID(n); id != v {
+			t.Errorf(`Got %q as the closest node to #b matching %q, want %q`, id, k, v)
+		}
+	}
+
+	if n, _ := Closest(`html`, doc); n != nil {
+		t.Errorf(`Expected no closest node for the document node`)
+	}
+}
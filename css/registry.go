@@ -0,0 +1,111 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+// Represents a function matching a registered pseudo class against an Element.
+type PseudoClassMatchFunc func(e Element) bool
+
+// Represents a function parsing the arguments of a registered functional pseudo class.
+// The arguments are given as component values with leading and trailing whitespace removed.
+// The returned value is kept in the Data field of the PseudoFunctionSelector.
+type PseudoFunctionParseFunc func(args []ComponentValue) (interface{}, error)
+
+// Represents a function matching a registered functional pseudo class against an Element.
+// The data is the value returned when parsing the arguments of the pseudo class.
+type PseudoFunctionMatchFunc func(data interface{}, e Element) bool
+
+// Represents the pseudo classes and pseudo elements known when parsing selectors.
+// Selectors parsed using a Registry fail to parse if they use pseudo classes or pseudo elements
+// that aren't registered, instead of parsing successfully and never matching anything.
+// Names are matched ASCII case-insensitively.
+type Registry struct {
+	pseudoClasses   map[string]PseudoClassMatchFunc // Match functions keyed by name, nil for the built-in ones.
+	pseudoFunctions map[string]*pseudoFunction      // Functional pseudo classes keyed by name.
+	pseudoElements  map[string]bool                 // Pseudo elements keyed by name.
+	positional      bool                            // If the jQuery positional pseudo classes are known.
+	defaultLanguage string                          // The language of elements without a language, matched by :lang().
+}
+
+// A registered functional pseudo class.
+type pseudoFunction struct {
+	parse PseudoFunctionParseFunc
+	match PseudoFunctionMatchFunc
+}
+
+// The pseudo elements known by a new Registry.
+// See http://dev.w3.org/csswg/css-pseudo-4/
+var standardPseudoElements = []string{
+	"after",
+	"backdrop",
+	"before",
+	"first-letter",
+	"first-line",
+	"marker",
+	"placeholder",
+	"selection",
+}
+
+// Creates and returns a new Registry knowing the pseudo classes supported by the default matching
+// machinery, the :nth-*, :not, :is, :where, :has, :lang and :dir functional pseudo classes and the standard pseudo elements.
+func NewRegistry() *Registry {
+	r := &Registry{
+		pseudoClasses:   map[string]PseudoClassMatchFunc{"scope": nil},
+		pseudoFunctions: map[string]*pseudoFunction{},
+		pseudoElements:  map[string]bool{},
+	}
+
+	for name := range pseudoClassMatchers {
+		r.pseudoClasses[name] = nil
+	}
+
+	for _, name := range standardPseudoElements {
+		r.pseudoElements[name] = true
+	}
+
+	return r
+}
+
+// Registers the pseudo class with the given name, matched using the function f.
+// A registered pseudo class replaces a built-in pseudo class with the same name.
+func (r *Registry) RegisterPseudoClass(name string, f PseudoClassMatchFunc) {
+	r.pseudoClasses[toASCIILower(name)] = f
+}
+
+// Registers the functional pseudo class with the given name, having its arguments parsed
+// using the function parse and being matched using the function match.
+// The :nth-*, :not, :is, :where, :has, :lang and :dir functional pseudo classes can't be replaced.
+func (r *Registry) RegisterPseudoFunction(name string, parse PseudoFunctionParseFunc, match PseudoFunctionMatchFunc) {
+	r.pseudoFunctions[toASCIILower(name)] = &pseudoFunction{parse, match}
+}
+
+// Registers the pseudo element with the given name.
+// Selectors with pseudo elements never match any element.
+func (r *Registry) RegisterPseudoElement(name string) {
+	r.pseudoElements[toASCIILower(name)] = true
+}
+
+// Sets the default language of documents, i.e. the language matched by :lang() for elements
+// without a language given by their own or an ancestor's lang or xml:lang attribute,
+// unless the document declares its language using a Content-Language pragma.
+func (r *Registry) SetDefaultLanguage(lang string) {
+	r.defaultLanguage = lang
+}
+
+// Returns the match function of the pseudo class with the given name,
+// and whether the pseudo class is known.
+func (r *Registry) pseudoClass(name string) (PseudoClassMatchFunc, bool) {
+	f, ok := r.pseudoClasses[toASCIILower(name)]
+	return f, ok
+}
+
+// Returns the functional pseudo class with the given name, nil if it's unknown.
+func (r *Registry) pseudoFunction(name string) *pseudoFunction {
+	return r.pseudoFunctions[toASCIILower(name)]
+}
+
+// Returns whether the pseudo element with the given name is known.
+func (r *Registry) hasPseudoElement(name string) bool {
+	return r.pseudoElements[toASCIILower(name)]
+}
@@ -0,0 +1,142 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// Returns the text content of the HTML node n.
+func textContent(n *html.Node) string {
+	var b bytes.Buffer
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+
+	f(n)
+	return b.String()
+}
+
+func testRegistry() *Registry {
+	r := NewRegistry()
+	r.RegisterPseudoClass("Heading", func(e Element) bool {
+		switch e.LocalName() {
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			return true
+		default:
+			return false
+		}
+	})
+
+	r.RegisterPseudoFunction("contains", func(args []ComponentValue) (interface{}, error) {
+		if len(args) == 1 {
+			if tk, ok := args[0].(Token); ok && (tk.Type() == String || tk.Type() == Ident) {
+				return tk.String(), nil
+			}
+		}
+
+		return nil, errors.New("Expected a string")
+	}, func(data interface{}, e Element) bool {
+		h, ok := e.(HTMLElement)
+		return ok && strings.Contains(textContent(h.Node), data.(string))
+	})
+
+	r.RegisterPseudoElement("-x-custom")
+	return r
+}
+
+var testRegistrySelectors = map[string]int{
+	`:heading`:                  2,
+	`:HEADING`:                  2,
+	`h3:heading`:                1,
+	`div:heading`:               0,
+	`h3:contains('palace')`:     1,
+	`h3:CONTAINS( "palace" )`:   1,
+	`div:contains(Boom)`:        0,
+	`:not(:contains(palace))`:   245,
+	`:is(:heading, :root)`:      3,
+	`div:first-child`:           51,
+	`div:FIRST-CHILD`:           51,
+	`div::-x-custom`:            0,
+	`div::before`:               0,
+	`p:after`:                   0,
+	`:nth-child(1 of :heading)`: 2,
+}
+
+func TestRegistry(t *testing.T) {
+	o := ParserOptions{Registry: testRegistry()}
+	for k, v := range testRegistrySelectors {
+		s, err := ParseSelectorWithOptions(NewTokenizer(k), o)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		if r := QueryAll(s, dom); len(r) != v {
+			t.Errorf(`Got %v nodes matching %q, want %v`, len(r), k, v)
+		}
+
+		checkCompiledMatching(t, s, k, dom, nil)
+	}
+}
+
+func TestRegistryUnknownNames(t *testing.T) {
+	tests := map[string]string{
+		`:hover`:               "Unknown pseudo class :hover at line 1, column 2",
+		`div:contains`:         "Unknown pseudo class :contains at line 1, column 5",
+		`:heading()`:           "Unknown pseudo class :heading() at line 1, column 2",
+		`::x-custom`:           "Unknown pseudo element ::x-custom at line 1, column 3",
+		`:not(:x)`:             "Unknown pseudo class :x at line 1, column 7",
+		`:nth-child(2n of :x)`: "Unknown pseudo class :x at line 1, column 19",
+		`:contains(a, b)`:      "Invalid arguments of :contains(): Expected a string at line 1, column 11",
+		`:contains()`:          "Invalid arguments of :contains(): Expected a string at line 1, column 11",
+	}
+
+	o := ParserOptions{Registry: testRegistry()}
+	for k, v := range tests {
+		_, err := ParseSelectorWithOptions(NewTokenizer(k), o)
+		if err == nil {
+			t.Errorf(`Expected error parsing %q`, k)
+		} else if err.Error() != v {
+			t.Errorf(`Got error %q parsing %q, want %q`, err, k, v)
+		}
+
+		if _, err := ParseSelectorFromString(k); err != nil {
+			t.Errorf(`Could not parse selector %q without a registry (%s)`, k, err)
+		}
+	}
+}
+
+func TestPseudoFunctionValues(t *testing.T) {
+	s, err := ParseSelectorFromString(`:x( a(b, c) "d" )`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := s[0].CompoundSelector.SimpleSelectors[0].(*PseudoFunctionSelector)
+	if len(x.Values) != 3 {
+		t.Fatalf(`Got %d argument values, want 3`, len(x.Values))
+	}
+
+	if f, ok := x.Values[0].(*FunctionValue); !ok || f.Name != "a" || len(f.Value) != 4 {
+		t.Errorf(`Expected the function value a(b, c), got %#v`, x.Values[0])
+	}
+
+	if tk, ok := x.Values[2].(Token); !ok || tk.Type() != String || tk.String() != "d" {
+		t.Errorf(`Expected the string value "d", got %#v`, x.Values[2])
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+// Represents a component value, i.e. a preserved Token, a *FunctionValue or a *SimpleBlock.
+// See http://www.w3.org/TR/css-syntax-3/#component-value
+type ComponentValue interface {
+	Position() Pos // The position of this component value.
+}
+
+// Represents a function.
+// See http://www.w3.org/TR/css-syntax-3/#function
+type FunctionValue struct {
+	Pos                    // The position of the function token.
+	Name  string           // The name of this function.
+	Value []ComponentValue // The arguments of this function.
+}
+
+// Represents a simple block.
+// See http://www.w3.org/TR/css-syntax-3/#simple-block
+type SimpleBlock struct {
+	Pos                         // The position of the token opening this block.
+	Associated TokenType        // One of LeftCurlyBracket, LeftSquareBracket or LeftParen.
+	Value      []ComponentValue // The contents of this block.
+}
+
+// Represents a rule, i.e. an *AtRule or a *QualifiedRule.
+type Rule interface {
+	Position() Pos // The position of this rule.
+}
+
+// Represents an at-rule.
+// See http://www.w3.org/TR/css-syntax-3/#at-rule
+type AtRule struct {
+	Pos                      // The position of the at-keyword token.
+	Name    string           // The name of this at-rule.
+	Prelude []ComponentValue // The prelude of this at-rule.
+	Block   *SimpleBlock     // The block of this at-rule, nil if it has none.
+}
+
+// Represents a qualified rule.
+// See http://www.w3.org/TR/css-syntax-3/#qualified-rule
+type QualifiedRule struct {
+	Pos                      // The position of the first token of this qualified rule.
+	Prelude []ComponentValue // The prelude of this qualified rule.
+	Block   *SimpleBlock     // The block of this qualified rule.
+}
+
+// Represents a declaration.
+// See http://www.w3.org/TR/css-syntax-3/#declaration
+type Declaration struct {
+	Pos                        // The position of the name of this declaration.
+	Name      string           // The name of this declaration.
+	Value     []ComponentValue // The value of this declaration.
+	Important bool             // If the important flag is set.
+}
+
+// Represents an item in a list of declarations, i.e. a *Declaration or an *AtRule.
+type DeclarationListItem interface {
+	Position() Pos // The position of this item.
+}
+
+// Represents a style sheet.
+// See http://www.w3.org/TR/css-syntax-3/#parse-a-stylesheet
+type Stylesheet struct {
+	Rules []Rule // The top-level rules of this style sheet.
+}
@@ -17,61 +17,140 @@ type SimpleSelectorMatchFunc func(SimpleSelector, *html.Node) bool
 
 // Matches the given SelectorGroup s against the HTML node n using the callback function
 // f if the default matching machinery didn't find a match.
+// The root element is matched if n is a document node.
 func MatchesSelectors(s SelectorsGroup, n *html.Node, f SimpleSelectorMatchFunc) bool {
-	for _, x := range s {
-		if MatchesSelector(x, n, f) {
-			return true
-		}
-	}
-
-	return false
+	e := htmlElement(n)
+	return e != nil && MatchesElement(s, e, htmlMatchFunc(f))
 }
 
 // Matches the given Selector s against the HTML node n using the callback function
 // f if the default matching machinery didn't find a match.
+// The root element is matched if n is a document node.
 func MatchesSelector(s *Selector, n *html.Node, f SimpleSelectorMatchFunc) bool {
-	return s.PseudoElement == nil && matchesCompoundSelector(s.CompoundSelector, n, f) == matched
+	e := htmlElement(n)
+	if e == nil {
+		return false
+	}
+
+	m := &matchState{f: htmlMatchFunc(f)}
+	return m.matchesSelector(s, e)
 }
 
 // Matches the given SimpleSelector s against the HTML node n using the callback function
 // f if the default matching machinery didn't find a match.
+// The root element is matched if n is a document node.
 func MatchesSimpleSelector(s SimpleSelector, n *html.Node, f SimpleSelectorMatchFunc) bool {
-	if n.Type == html.DocumentNode {
-		for n = n.FirstChild; n != nil; n = n.NextSibling {
-			if n.Type == html.ElementNode {
-				break
-			}
-		}
+	e := htmlElement(n)
+	if e == nil {
+		return false
 	}
 
-	if n.Type != html.ElementNode {
-		return false
+	m := &matchState{f: htmlMatchFunc(f)}
+	return m.matchesSimpleSelector(s, e)
+}
+
+// Matches the given SelectorGroup s against the Element e using the callback function
+// f if the default matching machinery didn't find a match.
+func MatchesElement(s SelectorsGroup, e Element, f ElementMatchFunc) bool {
+	m := &matchState{f: f}
+	return m.matchesSelectors(s, e)
+}
+
+// The state kept while matching selectors against nodes.
+// The same state may be used when matching several nodes against the same selectors.
+type matchState struct {
+	f        ElementMatchFunc       // Callback used when the default matching machinery didn't find a match.
+	scope    Element                // The element matched by :scope, the root element if nil.
+	has      map[relativeMatch]bool // Cached results of matching relative selectors.
+	contains map[compoundMatch]bool // Cached results of searching descendants matching compound selectors.
+	pragmas  map[Element]pragma     // Cached Content-Language pragmas keyed by root element.
+}
+
+// The key used to cache the results of matching relative selectors.
+type relativeMatch struct {
+	s *RelativeSelector
+	e Element
+}
+
+// The key used to cache the results of searching descendants matching compound selectors.
+type compoundMatch struct {
+	s *CompoundSelector
+	e Element
+}
+
+// Represents the element that a relative selector is anchored to.
+type anchor struct {
+	Element
+	Combinator
+}
+
+func (m *matchState) matchesSelectors(s SelectorsGroup, e Element) bool {
+	for _, x := range s {
+		if m.matchesSelector(x, e) {
+			return true
+		}
 	}
 
+	return false
+}
+
+func (m *matchState) matchesSelector(s *Selector, e Element) bool {
+	return s.PseudoElement == nil && m.matchesCompoundSelector(s.CompoundSelector, e, nil) == matched
+}
+
+func (m *matchState) matchesSimpleSelector(s SimpleSelector, e Element) bool {
 	switch x := s.(type) {
 	case *LocalNameSelector:
-		return strings.EqualFold(n.Data, x.Name)
+		return matchesLocalNameSelector(x, e)
 	case *AttributeSelector:
-		return matchesAttributeSelector(x, n)
+		return matchesAttributeSelector(x, e)
 	case *PseudoNegationSelector:
-		return !MatchesSimpleSelector(x.Selector, n, f)
+		return !m.matchesSelectors(x.group(), e)
+	case *PseudoIsSelector:
+		return m.matchesSelectors(x.Selectors, e)
+	case *PseudoWhereSelector:
+		return m.matchesSelectors(x.Selectors, e)
+	case *PseudoHasSelector:
+		return m.matchesPseudoHasSelector(x, e)
 	case *PseudoClassSelector:
-		if matchesPseudoClassSelector(x, n) {
+		if m.matchesPseudoClassSelector(x, e) {
 			return true
 		}
 	case *PseudoNthSelector:
-		if matchesPseudoNthSelector(x, n) {
+		if m.matchesPseudoNthSelector(x, e) {
+			return true
+		}
+	case *PseudoFunctionSelector:
+		if x.match != nil && x.match(x.Data, e) {
+			return true
+		}
+	case *PseudoLangSelector:
+		if m.matchesPseudoLangSelector(x, e) {
+			return true
+		}
+	case *PseudoDirSelector:
+		if matchesPseudoDirSelector(x, e) {
 			return true
 		}
 	}
 
-	if f != nil {
-		return f(s, n)
+	if m.f != nil {
+		return m.f(s, e)
 	}
 
 	return false
 }
 
+func (m *matchState) matchesSimpleSelectors(s []SimpleSelector, e Element) bool {
+	for _, x := range s {
+		if !m.matchesSimpleSelector(x, e) {
+			return false
+		}
+	}
+
+	return true
+}
+
 type matchingResult int
 
 const (
@@ -81,14 +160,18 @@ const (
 	restartFromClosestLaterSibling
 )
 
-func matchesCompoundSelector(s *CompoundSelector, n *html.Node, f SimpleSelectorMatchFunc) matchingResult {
-	for _, ss := range s.SimpleSelectors {
-		if !MatchesSimpleSelector(ss, n, f) {
-			return restartFromClosestLaterSibling
-		}
+// Matches the compound selector s and the ones preceding it against e.
+// If a is non-nil the first compound selector must match an element related to the anchor.
+func (m *matchState) matchesCompoundSelector(s *CompoundSelector, e Element, a *anchor) matchingResult {
+	if !m.matchesSimpleSelectors(s.SimpleSelectors, e) {
+		return restartFromClosestLaterSibling
 	}
 
 	if s.Prev == nil {
+		if a != nil && !a.relatesTo(e) {
+			return restartFromClosestLaterSibling
+		}
+
 		return matched
 	}
 
@@ -102,180 +185,487 @@ func matchesCompoundSelector(s *CompoundSelector, n *html.Node, f SimpleSelector
 	}
 
 	for {
-		var nextNode *html.Node
 		if siblings {
-			nextNode = n.PrevSibling
+			e = e.PrevSibling()
 		} else {
-			nextNode = n.Parent
+			e = e.Parent()
 		}
 
-		if nextNode == nil {
+		if e == nil {
 			return candidateNotFound
-		} else {
-			n = nextNode
 		}
 
-		if n.Type == html.ElementNode {
-			r := matchesCompoundSelector(s.Prev.CompoundSelector, n, f)
-			if r == matched || r == notMatched {
+		r := m.matchesCompoundSelector(s.Prev.CompoundSelector, e, a)
+		if r == matched || r == notMatched {
+			return r
+		}
+
+		switch s.Prev.Combinator {
+		case Child:
+			return restartFromClosestDescendant
+		case NextSibling:
+			return r
+		case LaterSibling:
+			if r == restartFromClosestDescendant {
 				return r
 			}
+		}
+	}
+}
 
-			switch s.Prev.Combinator {
-			case Child:
-				return restartFromClosestDescendant
-			case NextSibling:
-				return r
-			case LaterSibling:
-				if r == restartFromClosestDescendant {
-					return r
-				}
+// Returns whether e is related to the anchor element by the anchor combinator.
+func (a *anchor) relatesTo(e Element) bool {
+	switch a.Combinator {
+	case Child:
+		return e.Parent() == a.Element
+	case Descendant:
+		for p := e.Parent(); p != nil; p = p.Parent() {
+			if p == a.Element {
+				return true
 			}
 		}
+	case NextSibling:
+		return e.PrevSibling() == a.Element
+	case LaterSibling:
+		for p := e.PrevSibling(); p != nil; p = p.PrevSibling() {
+			if p == a.Element {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Matches the :has() pseudo class against e.
+// See http://dev.w3.org/csswg/selectors-4/#relational
+func (m *matchState) matchesPseudoHasSelector(s *PseudoHasSelector, e Element) bool {
+	for _, x := range s.Selectors {
+		if m.matchesRelativeSelector(x, e) {
+			return true
+		}
 	}
+
+	return false
 }
 
-func matchesAttributeSelector(s *AttributeSelector, n *html.Node) bool {
-	for _, a := range n.Attr {
-		if a.Key != s.Name {
-			continue
+// Returns whether any element related to e matches the relative selector s.
+// The results are cached since the same element is commonly matched several times,
+// e.g. when matching descendant combinators or when querying all elements of a document.
+func (m *matchState) matchesRelativeSelector(s *RelativeSelector, e Element) bool {
+	k := relativeMatch{s, e}
+	if r, ok := m.has[k]; ok {
+		return r
+	}
+
+	r := m.searchRelativeSelector(s, e)
+	if m.has == nil {
+		m.has = make(map[relativeMatch]bool)
+	}
+
+	m.has[k] = r
+	return r
+}
+
+// Searches the elements that may match the relative selector s anchored to e.
+func (m *matchState) searchRelativeSelector(s *RelativeSelector, e Element) bool {
+	// Anything matching relative to a child or the next sibling of e matches relative
+	// to e as well when using descendant and later sibling combinators respectively,
+	// which leaves the elements matching relative to e only to be searched.
+	a := &anchor{e, Child}
+	switch s.Combinator {
+	case Descendant:
+		for c := e.FirstChild(); c != nil; c = c.NextSibling() {
+			if m.matchesRelativeSelector(s, c) {
+				return true
+			}
+		}
+	case NextSibling:
+		a.Combinator = NextSibling
+	case LaterSibling:
+		if c := e.NextSibling(); c != nil && m.matchesRelativeSelector(s, c) {
+			return true
+		}
+
+		a.Combinator = NextSibling
+	}
+
+	// The depth of the search is limited unless there's a descendant combinator.
+	depth := 0
+	if a.Combinator == Child {
+		depth++
+	}
+
+	cs := s.Selector.CompoundSelector
+	for x := cs; x.Prev != nil; x = x.Prev.CompoundSelector {
+		switch x.Prev.Combinator {
+		case Child:
+			depth++
+		case Descendant:
+			depth = -1
 		}
 
-		switch s.Match {
-		case Exists:
+		if depth == -1 {
+			break
+		}
+	}
+
+	var search func(Element, int) bool
+	search = func(x Element, d int) bool {
+		if m.matchesCompoundSelector(cs, x, a) == matched {
 			return true
-		case Equals:
-			return a.Val == s.Value
-		case Includes:
-			for _, x := range strings.FieldsFunc(a.Val, IsSpace) {
-				if x == s.Value {
-					return true
-				}
+		}
+
+		if d == depth || !m.containsCompoundSelector(cs, x) {
+			return false
+		}
+
+		for c := x.FirstChild(); c != nil; c = c.NextSibling() {
+			if search(c, d+1) {
+				return true
 			}
-		case Begins:
-			return strings.HasPrefix(a.Val, s.Value)
-		case Ends:
-			return strings.HasSuffix(a.Val, s.Value)
-		case Contains:
-			return strings.Contains(a.Val, s.Value)
-		case Hyphens:
-			return a.Val == s.Value || strings.HasPrefix(a.Val, s.Value+"-")
 		}
 
 		return false
 	}
 
+	if a.Combinator == Child {
+		for c := e.FirstChild(); c != nil; c = c.NextSibling() {
+			if search(c, 1) {
+				return true
+			}
+		}
+	} else {
+		for c := e.NextSibling(); c != nil; c = c.NextSibling() {
+			if search(c, 0) {
+				return true
+			}
+		}
+	}
+
 	return false
 }
 
-func matchesPseudoClassSelector(s *PseudoClassSelector, n *html.Node) bool {
-	switch s.Value {
-	case "first-child":
-		return matchesFirstOrLastChild(n, true)
-	case "last-child":
-		return matchesFirstOrLastChild(n, false)
-	case "only-child":
-		return matchesFirstOrLastChild(n, true) && matchesFirstOrLastChild(n, false)
-	case "first-of-type":
-		return matchesNthChild(n, 0, 1, true, false)
-	case "last-of-type":
-		return matchesNthChild(n, 0, 1, true, true)
-	case "only-of-type":
-		return matchesNthChild(n, 0, 1, true, false) && matchesNthChild(n, 0, 1, true, true)
-	case "root":
-		return n.Parent != nil && n.Parent.Type == html.DocumentNode
-	case "empty":
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			if c.Type == html.ElementNode {
+// Returns whether any descendant of e matches the simple selectors of s,
+// ignoring the compound selectors preceding s.
+func (m *matchState) containsCompoundSelector(s *CompoundSelector, e Element) bool {
+	k := compoundMatch{s, e}
+	if r, ok := m.contains[k]; ok {
+		return r
+	}
+
+	r := false
+	for c := e.FirstChild(); c != nil && !r; c = c.NextSibling() {
+		r = m.matchesSimpleSelectors(s.SimpleSelectors, c) || m.containsCompoundSelector(s, c)
+	}
+
+	if m.contains == nil {
+		m.contains = make(map[compoundMatch]bool)
+	}
+
+	m.contains[k] = r
+	return r
+}
+
+func matchesLocalNameSelector(s *LocalNameSelector, e Element) bool {
+	if s.Name != "*" {
+		if e.IsHTML() {
+			if !strings.EqualFold(e.LocalName(), s.Name) {
 				return false
 			}
+		} else if e.LocalName() != s.Name {
+			return false
+		}
+	}
 
-			if c.Type == html.TextNode && len(c.Data) > 0 {
-				return false
+	return s.Namespace == nil || s.Namespace.Prefix == "*" || s.Namespace.URL == e.Namespace()
+}
+
+func matchesAttributeSelector(s *AttributeSelector, e Element) bool {
+	for i, c := 0, e.NumAttributes(); i < c; i++ {
+		a := e.Attribute(i)
+		if a.Name != s.Name {
+			continue
+		}
+
+		if s.Namespace == nil {
+			if a.Namespace != "" {
+				continue
 			}
+		} else if s.Namespace.Prefix != "*" && s.Namespace.URL != a.Namespace {
+			continue
+		}
+
+		if matchesAttributeValue(s, a.Value, ignoresAttributeCase(s, e, &a)) {
+			return true
 		}
+	}
+
+	return false
+}
 
+// Returns whether the value of the attribute a of e should be compared
+// ASCII case-insensitively when matching the attribute selector s.
+// See https://html.spec.whatwg.org/multipage/semantics-other.html#case-sensitivity-of-selectors
+func ignoresAttributeCase(s *AttributeSelector, e Element, a *Attr) bool {
+	switch s.Case {
+	case IgnoreCase:
 		return true
-	default:
+	case SensitiveCase:
 		return false
+	default:
+		return a.Namespace == "" && caseInsensitiveAttributes[a.Name] && isHTMLElement(e)
 	}
 }
 
-func matchesPseudoNthSelector(s *PseudoNthSelector, n *html.Node) bool {
-	switch s.Name {
-	case "nth-child":
-		return matchesNthChild(n, s.A, s.B, false, false)
-	case "nth-last-child":
-		return matchesNthChild(n, s.A, s.B, false, true)
-	case "nth-of-type":
-		return matchesNthChild(n, s.A, s.B, true, false)
-	case "nth-last-of-type":
-		return matchesNthChild(n, s.A, s.B, true, true)
-	default:
-		return false
+// Returns whether e is an HTML element in an HTML document.
+func isHTMLElement(e Element) bool {
+	return e.IsHTML() && e.Namespace() == XHTMLNamespace
+}
+
+// The attributes of HTML elements whose values are compared ASCII case-insensitively by default.
+var caseInsensitiveAttributes = map[string]bool{
+	"accept":         true,
+	"accept-charset": true,
+	"align":          true,
+	"alink":          true,
+	"axis":           true,
+	"bgcolor":        true,
+	"charset":        true,
+	"checked":        true,
+	"clear":          true,
+	"codetype":       true,
+	"color":          true,
+	"compact":        true,
+	"declare":        true,
+	"defer":          true,
+	"dir":            true,
+	"direction":      true,
+	"disabled":       true,
+	"enctype":        true,
+	"face":           true,
+	"frame":          true,
+	"hreflang":       true,
+	"http-equiv":     true,
+	"lang":           true,
+	"language":       true,
+	"link":           true,
+	"media":          true,
+	"method":         true,
+	"multiple":       true,
+	"nohref":         true,
+	"noresize":       true,
+	"noshade":        true,
+	"nowrap":         true,
+	"readonly":       true,
+	"rel":            true,
+	"rev":            true,
+	"rules":          true,
+	"scope":          true,
+	"scrolling":      true,
+	"selected":       true,
+	"shape":          true,
+	"target":         true,
+	"text":           true,
+	"type":           true,
+	"valign":         true,
+	"valuetype":      true,
+	"vlink":          true,
+}
+
+func matchesAttributeValue(s *AttributeSelector, v string, ignoreCase bool) bool {
+	sv := s.Value
+	if ignoreCase {
+		v, sv = toASCIILower(v), toASCIILower(sv)
 	}
+
+	switch s.Match {
+	case Exists:
+		return true
+	case Equals:
+		return v == sv
+	case Includes:
+		return containsField(v, sv)
+	case Begins:
+		return strings.HasPrefix(v, sv)
+	case Ends:
+		return strings.HasSuffix(v, sv)
+	case Contains:
+		return strings.Contains(v, sv)
+	case Hyphens:
+		return v == sv || strings.HasPrefix(v, sv+"-")
+	}
+
+	return false
 }
 
-func matchesFirstOrLastChild(n *html.Node, first bool) bool {
-	for {
-		var x *html.Node
-		if first {
-			x = n.PrevSibling
-		} else {
-			x = n.NextSibling
+// Returns whether x is one of the whitespace-separated fields of v.
+func containsField(v, x string) bool {
+	for f, i := nextField(v, 0); f != ""; f, i = nextField(v, i) {
+		if f == x {
+			return true
 		}
+	}
 
-		if x == nil {
-			x = n.Parent
-			return x != nil && x.Type != html.DocumentNode
-		} else {
-			if x.Type == html.ElementNode {
-				return false
-			}
+	return false
+}
+
+// Returns the first whitespace-separated field of v starting at index i or later,
+// and the index following it. The empty string is returned when there are no more fields.
+func nextField(v string, i int) (string, int) {
+	for i < len(v) && IsSpace(rune(v[i])) {
+		i++
+	}
+
+	j := i
+	for j < len(v) && !IsSpace(rune(v[j])) {
+		j++
+	}
+
+	return v[i:j], j
+}
+
+// Returns s with the ASCII upper case letters converted to lower case.
+func toASCIILower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+
+		return r
+	}, s)
+}
+
+func (m *matchState) matchesPseudoClassSelector(s *PseudoClassSelector, e Element) bool {
+	if s.match != nil {
+		return s.match(e)
+	}
+
+	if toASCIILower(s.Value) == "scope" {
+		return m.matchesScope(e)
+	}
+
+	if f, ok := pseudoClassMatchers[toASCIILower(s.Value)]; ok {
+		return f(e)
+	}
+
+	return false
+}
+
+// Returns whether e is the scoping root, or the root element if there is no scoping root.
+// The matchState may be nil.
+// See http://dev.w3.org/csswg/selectors-4/#the-scope-pseudo
+func (m *matchState) matchesScope(e Element) bool {
+	if m == nil || m.scope == nil {
+		return e.IsRoot()
+	}
 
-			n = x
+	return e == m.scope
+}
+
+// The functions used to match the pseudo classes supported by the default matching machinery.
+var pseudoClassMatchers = map[string]func(Element) bool{
+	"first-child": func(e Element) bool {
+		return e.PrevSibling() == nil
+	},
+	"last-child": func(e Element) bool {
+		return e.NextSibling() == nil
+	},
+	"only-child": func(e Element) bool {
+		return e.PrevSibling() == nil && e.NextSibling() == nil
+	},
+	"first-of-type": func(e Element) bool {
+		return matchesNthChild(e, 0, 1, true, false, nil)
+	},
+	"last-of-type": func(e Element) bool {
+		return matchesNthChild(e, 0, 1, true, true, nil)
+	},
+	"only-of-type": func(e Element) bool {
+		return matchesNthChild(e, 0, 1, true, false, nil) && matchesNthChild(e, 0, 1, true, true, nil)
+	},
+	"root": func(e Element) bool {
+		return e.IsRoot()
+	},
+	"empty": func(e Element) bool {
+		return e.IsEmpty()
+	},
+	"link":              isLink,
+	"any-link":          isLink,
+	"checked":           isChecked,
+	"default":           isDefault,
+	"disabled":          isDisabled,
+	"enabled":           isEnabled,
+	"indeterminate":     isIndeterminate,
+	"optional":          isOptional,
+	"placeholder-shown": isPlaceholderShown,
+	"read-only":         isReadOnly,
+	"read-write":        isReadWrite,
+	"required":          isRequired,
+	"target": func(Element) bool {
+		// Matched if the Registry knows the fragment identifier, see Registry.SetTarget.
+		return false
+	},
+}
+
+func (m *matchState) matchesPseudoNthSelector(s *PseudoNthSelector, e Element) bool {
+	isOfType, fromEnd, ok := nthSelectorKind(s.Name)
+	if !ok {
+		return false
+	}
+
+	var of func(Element) bool
+	if s.Of != nil {
+		of = func(x Element) bool {
+			return m.matchesSelectors(s.Of, x)
 		}
 	}
+
+	return matchesNthChild(e, s.A, s.B, isOfType, fromEnd, of)
+}
+
+// Returns how to count the siblings for the nth pseudo class with the given name,
+// and whether the name is supported at all.
+func nthSelectorKind(name string) (isOfType, fromEnd, ok bool) {
+	switch name {
+	case "nth-child":
+		return false, false, true
+	case "nth-last-child":
+		return false, true, true
+	case "nth-of-type":
+		return true, false, true
+	case "nth-last-of-type":
+		return true, true, true
+	default:
+		return false, false, false
+	}
 }
 
-func matchesNthChild(n *html.Node, a, b int, isOfType, fromEnd bool) bool {
-	if n.Parent == nil || n.Parent.Type == html.DocumentNode {
+// Returns whether e is the An+B-th of its siblings, counting only the siblings of the same type
+// if isOfType is set and only the siblings matching of if it's not nil.
+// An element not matching of is never matched. Unlike Selectors Level 3, an element without
+// a parent, e.g. the root of a fragment or a detached subtree, is matched as its only sibling.
+// See http://dev.w3.org/csswg/selectors-4/#child-index
+func matchesNthChild(e Element, a, b int, isOfType, fromEnd bool, of func(Element) bool) bool {
+	if of != nil && !of(e) {
 		return false
 	}
 
-	x := n
 	i := 1
-	for {
+	for x := e; ; {
 		if fromEnd {
-			s := x.NextSibling
-			if s == nil {
-				break
-			} else {
-				x = s
-			}
+			x = x.NextSibling()
 		} else {
-			s := x.PrevSibling
-			if s == nil {
-				break
-			} else {
-				x = s
-			}
+			x = x.PrevSibling()
 		}
 
-		if x.Type == html.ElementNode {
-			if isOfType {
-				if n.Data == x.Data {
-					i += 1
-				}
-			} else {
-				i += 1
-			}
+		if x == nil {
+			break
 		}
-	}
 
-	if a == 0 {
-		return b == i
+		if (!isOfType || (x.LocalName() == e.LocalName() && x.Namespace() == e.Namespace())) && (of == nil || of(x)) {
+			i++
+		}
 	}
 
-	return ((i-b)/a) >= 0 && ((i-b)%a) == 0
+	return matchesAnPlusB(a, b, i)
 }
@@ -6,17 +6,21 @@ package css
 
 import (
 	"bytes"
+	"encoding/json"
+	"io/ioutil"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 var testSelectors = map[string]int{
 	`*`:                           251,
 	`:root`:                       1,
-	`:empty`:                      2,
+	`:empty`:                      3,
 	`div:first-child`:             51,
 	`div:nth-child(even)`:         106,
 	`div:nth-child(2n)`:           106,
@@ -62,6 +66,10 @@ var testSelectors = map[string]int{
 	`div[class~=dialog]`:          51,
 	`head > :not(meta)`:           2,
 	`head > :not(:last-child)`:    2,
+	`div:has(> h3)`:               1,
+	`head:has(meta, script)`:      1,
+	`:has(> body)`:                1,
+	`:not(:has(*))`:               196,
 }
 
 var dom *html.Node
@@ -138,3 +146,478 @@ func containsMatcher(s SimpleSelector, n *html.Node) bool {
 	f(n, &b)
 	return strings.Contains(b.String(), fs.Arguments)
 }
+
+const namespaceHTML = `<!DOCTYPE html>
+<html><body>
+<div><a href="#x">html</a></div>
+<svg viewBox="0 0 10 10">
+	<a xlink:href="#y"><rect width="1"/></a>
+	<foreignObject><div>inside</div></foreignObject>
+	<rect/>
+</svg>
+<math><mi>x</mi></math>
+</body></html>`
+
+var testNamespaceSelectors = map[string]int{
+	`rect`:                    2,
+	`svg|rect`:                2,
+	`html|rect`:               0,
+	`*|rect`:                  2,
+	`|rect`:                   0,
+	`svg|*`:                   5,
+	`html|a`:                  1,
+	`svg|a`:                   1,
+	`a`:                       2,
+	`html|div`:                2,
+	`svg|foreignObject div`:   1,
+	`math|*`:                  2,
+	`[href]`:                  1,
+	`[xlink|href]`:            1,
+	`[*|href]`:                2,
+	`[|href]`:                 1,
+	`svg|a[xlink|href="#y"]`:  1,
+	`:not(svg|*):not(math|*)`: 6,
+}
+
+func TestNamespaceMatching(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(namespaceHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	namespaces := map[string]string{
+		"html":  XHTMLNamespace,
+		"svg":   SVGNamespace,
+		"math":  MathMLNamespace,
+		"xlink": XLinkNamespace,
+	}
+
+	for k, v := range testNamespaceSelectors {
+		s, err := ParseSelectorWithNamespaces(NewTokenizer(k), namespaces)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+		} else if r := QueryAll(s, doc); len(r) != v {
+			t.Errorf(`Got %v nodes matching %q, want %v`, len(r), k, v)
+		}
+	}
+}
+
+func TestDefaultNamespaceMatching(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(namespaceHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]int{
+		`rect`:      2,
+		`*`:         5,
+		`[width]`:   1,
+		`:not(a)`:   4,
+		`*|a`:       2,
+		`html|body`: 1,
+	}
+
+	namespaces := map[string]string{
+		"":     SVGNamespace,
+		"html": XHTMLNamespace,
+	}
+
+	for k, v := range tests {
+		s, err := ParseSelectorWithNamespaces(NewTokenizer(k), namespaces)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+		} else if r := QueryAll(s, doc); len(r) != v {
+			t.Errorf(`Got %v nodes matching %q, want %v`, len(r), k, v)
+		}
+	}
+}
+
+func TestUndeclaredNamespace(t *testing.T) {
+	for _, k := range []string{`svg|rect`, `[xlink|href]`, `foo|`, `[*]`, `[svg|*]`} {
+		if _, err := ParseSelectorFromString(k); err == nil {
+			t.Errorf(`Expected error parsing %q`, k)
+		}
+	}
+}
+
+const attributeCaseHTML = `<!DOCTYPE html>
+<html><body>
+<input type="TEXT" title="Foo" lang="EN-us">
+<input type="text" title="foo" lang="en">
+<p dir="RTL" data-x="ABC def"></p>
+<svg><a type="TEXT" title="Foo"/></svg>
+</body></html>`
+
+var testAttributeCaseSelectors = map[string]int{
+	`[type=text]`:         2,
+	`[type=text s]`:       1,
+	`[type=TEXT S]`:       2,
+	`[title=foo]`:         1,
+	`[title=foo i]`:       3,
+	`[title="FOO" I]`:     3,
+	`[lang|=en]`:          2,
+	`[lang^=en-US]`:       1,
+	`[dir=rtl]`:           1,
+	`[data-x~=abc]`:       0,
+	`[data-x~=abc i]`:     1,
+	`[data-x*="c D" i]`:   1,
+	`[data-x$=DEF i]`:     1,
+	`svg [type=text]`:     0,
+	`svg [type=text i]`:   1,
+	`input:not([type=x])`: 2,
+}
+
+func TestAttributeCaseMatching(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(attributeCaseHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range testAttributeCaseSelectors {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+		} else if r := QueryAll(s, doc); len(r) != v {
+			t.Errorf(`Got %v nodes matching %q, want %v`, len(r), k, v)
+		}
+	}
+
+	for _, k := range []string{`[type=text x]`, `[type=text i s]`, `[type i]`, `[type=text "i"]`} {
+		if _, err := ParseSelectorFromString(k); err == nil {
+			t.Errorf(`Expected error parsing %q`, k)
+		}
+	}
+}
+
+const nthOfHTML = `<!DOCTYPE html>
+<html><body><table>
+<tr class="hidden"><td>1</td></tr>
+<tr><td>2</td></tr>
+<tr class="hidden"><td>3</td></tr>
+<tr><td>4</td></tr>
+<tr><td>5</td></tr>
+<tr class="hidden"><td>6</td></tr>
+<tr><td>7</td></tr>
+</table></body></html>`
+
+var testNthOfSelectors = map[string]int{
+	`tr:nth-child(odd of :not(.hidden))`:       2,
+	`tr:nth-child(even of :not(.hidden))`:      2,
+	`tr:nth-child(odd)`:                        4,
+	`tr:nth-child(1 of .hidden)`:               1,
+	`tr:nth-last-child(1 of .hidden)`:          1,
+	`tr:nth-last-child(-n+2 of :not(.hidden))`: 2,
+	`tr:nth-child(n of td)`:                    0,
+	`.hidden:nth-child(2 of :not(.hidden))`:    0,
+	`tr:nth-child(n+1 of tr, .hidden)`:         7,
+	`td:nth-child(1 of td)`:                    7,
+}
+
+func TestNthOfMatching(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(nthOfHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range testNthOfSelectors {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+		} else if r := QueryAll(s, doc); len(r) != v {
+			t.Errorf(`Got %v nodes matching %q, want %v`, len(r), k, v)
+		}
+	}
+
+	for _, k := range []string{`:nth-of-type(1 of p)`, `:nth-last-of-type(odd of p)`, `:nth-child(odd of)`, `:nth-child(odd of ::before)`} {
+		if _, err := ParseSelectorFromString(k); err == nil {
+			t.Errorf(`Expected error parsing %q`, k)
+		}
+	}
+}
+
+var testEquivalentSelectors = map[string]string{
+	`:is(h2, h3)`:                   `h2, h3`,
+	`:is(div, h3) > h3`:             `div > h3, h3 > h3`,
+	`:is(#scene1, #test) > .dialog`: `#scene1 > .dialog, #test > .dialog`,
+	`:where(.dialog) div`:           `.dialog div`,
+	`:where(div, h2):first-child`:   `div:first-child, h2:first-child`,
+	`:not(.dialog, .character)`:     `:not(.dialog):not(.character)`,
+	`:is(:is(div))`:                 `div`,
+	`:not(:not(div))`:               `div`,
+	`:is(div, ::before, 12, [x)])`:  `div`,
+	`div:is(#test > *)`:             `#test > div`,
+	`:is(div>div)+div`:              `div > div + div`,
+	`h2~div:not(.dialog)`:           `h2 ~ div:not(.dialog)`,
+}
+
+func TestEquivalentSelectorMatching(t *testing.T) {
+	for k, v := range testEquivalentSelectors {
+		r1, err := QuerySelectorAll(k, dom)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		r2, err := QuerySelectorAll(v, dom)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, v, err)
+			continue
+		}
+
+		if len(r2) == 0 {
+			t.Errorf(`No nodes matching %q`, v)
+		}
+
+		if !reflect.DeepEqual(r1, r2) {
+			t.Errorf(`Got %v nodes matching %q, want the %v nodes matching %q`, len(r1), k, len(r2), v)
+		}
+	}
+}
+
+func TestInvalidSelectorLists(t *testing.T) {
+	for _, k := range []string{`:not()`, `:not(div,)`, `:not(::before)`, `:not(div`, `:is(div`, `:not(.a, 12)`} {
+		if _, err := ParseSelectorFromString(k); err == nil {
+			t.Errorf(`Expected error parsing %q`, k)
+		}
+	}
+}
+
+var testSyntaxErrors = []struct {
+	input    string
+	unit     ColumnUnit
+	start    Pos
+	end      int
+	expected []string
+}{
+	{"div,\n  p > 12", RuneColumns, Pos{10, 2, 6}, 13, nil},
+	{"a[href=x y]", RuneColumns, Pos{9, 1, 10}, 10, []string{"attribute modifier"}},
+	{"a\n\tb\n  [x", RuneColumns, Pos{9, 3, 5}, 9, []string{"attribute value"}},
+	{"[a=\"\U0001F600\" x]", RuneColumns, Pos{10, 1, 8}, 11, []string{"attribute modifier"}},
+	{"[a=\"\U0001F600\" x]", UTF16Columns, Pos{10, 1, 9}, 11, []string{"attribute modifier"}},
+	{"a b\r\n.12", RuneColumns, Pos{5, 2, 1}, 8, nil},
+	{"\nsvg|rect", RuneColumns, Pos{1, 2, 1}, 9, nil},
+	{"p:nth-child(2n+)", RuneColumns, Pos{12, 1, 13}, 15, nil},
+	{"p:not(div::before)", RuneColumns, Pos{6, 1, 7}, 17, nil},
+	{"p:is(div", RuneColumns, Pos{5, 1, 6}, 8, nil},
+	{"p:is(div ~ *)~", RuneColumns, Pos{14, 1, 15}, 14, nil},
+	{"p/**/q", RuneColumns, Pos{5, 1, 6}, 6, []string{"' '", "'>'", "'+'", "'~'"}},
+}
+
+func TestSyntaxError(t *testing.T) {
+	for _, test := range testSyntaxErrors {
+		_, err := ParseSelector(NewTokenizerWithOptions(test.input, TokenizerOptions{ColumnUnit: test.unit}))
+		e, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf(`Got error %v parsing %q, want a syntax error`, err, test.input)
+			continue
+		}
+
+		if e.Start != test.start || e.End.Offset != test.end || !reflect.DeepEqual(e.Expected, test.expected) {
+			t.Errorf(`Got error %q spanning %v-%v expecting %q parsing %q, want %v-%v expecting %q`,
+				e, e.Start, e.End.Offset, e.Expected, test.input, test.start, test.end, test.expected)
+		}
+	}
+}
+
+func TestSelectorComments(t *testing.T) {
+	tests := map[string]string{
+		`a/**/.b/* c */[d]`:                 `a.b[d]`,
+		`a /**/ b, /* c */ d/**/>e`:         `a b, d > e`,
+		`p:nth-child(/**/2n/**/+/**/1/**/)`: `p:nth-child(2n+1)`,
+		`:is(a/**/,/**/b) :not(/**/c)`:      `:is(a, b) :not(c)`,
+	}
+
+	for k, v := range tests {
+		for _, preserve := range []bool{false, true} {
+			g, err := ParseSelector(NewTokenizerWithOptions(k, TokenizerOptions{PreserveComments: preserve}))
+			if err != nil {
+				t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			} else if s := g.String(); s != v {
+				t.Errorf(`Got selector %q parsing %q, want %q`, s, k, v)
+			}
+		}
+	}
+}
+
+var testRelativeSelectors = []struct {
+	subject  string
+	relative []string
+}{
+	{`div`, []string{`> h3`}},
+	{`div`, []string{`h3`}},
+	{`div`, []string{`.character`}},
+	{`*`, []string{`+ .direction`}},
+	{`*`, []string{`+ div`}},
+	{`h2`, []string{`~ div`}},
+	{`div`, []string{`div > h3`}},
+	{`div`, []string{`> div + div`}},
+	{`body`, []string{`> div > div.dialog`}},
+	{`div`, []string{`~ div .direction`}},
+	{`h3`, []string{`~ div > div`}},
+	{`*`, []string{`> [id]`}},
+	{`div`, []string{`+ div`, `> h2`}},
+	{`div`, []string{`.dialog > div:first-child`}},
+}
+
+func TestRelativeSelectorMatching(t *testing.T) {
+	for _, x := range testRelativeSelectors {
+		k := x.subject + ":has(" + strings.Join(x.relative, ", ") + ")"
+		r, err := QuerySelectorAll(k, dom)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		// Brute force the expected result by marking each subject with an
+		// attribute that the absolutized relative selectors are anchored to.
+		subjects, _ := QuerySelectorAll(x.subject, dom)
+		var e []*html.Node
+		for _, n := range subjects {
+			n.Attr = append(n.Attr, html.Attribute{Key: "data-anchor"})
+			for _, rel := range x.relative {
+				if m, _ := QuerySelectorAll(`[data-anchor] `+rel, dom); len(m) > 0 {
+					e = append(e, n)
+					break
+				}
+			}
+
+			n.Attr = n.Attr[:len(n.Attr)-1]
+		}
+
+		if len(e) == 0 {
+			t.Errorf(`No nodes expected to match %q`, k)
+		}
+
+		if !reflect.DeepEqual(r, e) {
+			t.Errorf(`Got %v nodes matching %q, want %v`, len(r), k, len(e))
+		}
+	}
+}
+
+func TestRelativeSelectorMatchingDeepTree(t *testing.T) {
+	const depth = 5000
+	doc := &html.Node{Type: html.DocumentNode}
+	p := doc
+	for i := 0; i < depth; i++ {
+		c := &html.Node{Type: html.ElementNode, Data: "div"}
+		p.AppendChild(c)
+		p = c
+	}
+
+	p.AppendChild(&html.Node{Type: html.ElementNode, Data: "span"})
+	for k, v := range map[string]int{`div:has(span)`: depth, `div:has(> span)`: 1, `div:has(div span)`: depth - 1, `:has(+ span)`: 0} {
+		if r, err := QuerySelectorAll(k, doc); err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+		} else if len(r) != v {
+			t.Errorf(`Got %v nodes matching %q, want %v`, len(r), k, v)
+		}
+	}
+}
+
+// A test case of testdata/structural.json, the selectors in tests mapped to the ids of the elements
+// they match in document order. The source is parsed as a document, a fragment or XML depending
+// on the mode, and if detach is set the element with that id is removed from the document and matched
+// against as a detached subtree.
+type structuralTest struct {
+	Description string
+	Mode        string
+	Source      string
+	Detach      string
+	Tests       map[string]string
+}
+
+func TestStructuralMatching(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/structural.json")
+	if err != nil {
+		t.Fatal("Could not read structural.json")
+	}
+
+	var tests []structuralTest
+	if err := json.Unmarshal(data, &tests); err != nil {
+		t.Fatal("structural.json is invalid")
+	}
+
+	for _, test := range tests {
+		roots, err := structuralTestRoots(test)
+		if err != nil {
+			t.Errorf(`Could not parse %q (%s)`, test.Description, err)
+			continue
+		}
+
+		for k, v := range test.Tests {
+			s, err := ParseSelectorFromString(k)
+			if err != nil {
+				t.Errorf(`Could not parse selector %q (%s)`, k, err)
+				continue
+			}
+
+			m, err := Compile(s)
+			if err != nil {
+				t.Errorf(`Could not compile selector %q (%s)`, k, err)
+				continue
+			}
+
+			var ids []string
+			for _, root := range roots {
+				TraverseElements(root, func(e Element) {
+					r := MatchesElement(s, e, nil)
+					if r != m.MatchesElement(e) {
+						t.Errorf(`Got different results matching %q compiled and not in %q`, k, test.Description)
+					}
+
+					if id, _ := attributeValue(e, "id"); r && id != "" {
+						ids = append(ids, id)
+					}
+				})
+			}
+
+			if r := strings.Join(ids, " "); r != v {
+				t.Errorf(`Got %q matching %q in %q, want %q`, r, k, test.Description, v)
+			}
+		}
+	}
+}
+
+// Returns the root elements of the tree of the structural test case.
+func structuralTestRoots(test structuralTest) ([]Element, error) {
+	switch test.Mode {
+	case "xml":
+		doc, err := ParseXML(strings.NewReader(test.Source))
+		if err != nil {
+			return nil, err
+		}
+
+		return []Element{XMLRootElement(doc)}, nil
+	case "fragment":
+		body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+		nodes, err := html.ParseFragment(strings.NewReader(test.Source), body)
+		if err != nil {
+			return nil, err
+		}
+
+		var roots []Element
+		for _, n := range nodes {
+			if e := htmlElementOrNil(n); e != nil {
+				roots = append(roots, e)
+			}
+		}
+
+		return roots, nil
+	default:
+		doc, err := html.Parse(strings.NewReader(test.Source))
+		if err != nil {
+			return nil, err
+		}
+
+		if test.Detach == "" {
+			return []Element{htmlElement(doc)}, nil
+		}
+
+		n := find(doc, func(n *html.Node) bool {
+			return nodeID(n) == test.Detach
+		})
+
+		n.Parent.RemoveChild(n)
+		return []Element{HTMLElement{n}}, nil
+	}
+}
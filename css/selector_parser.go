@@ -12,19 +12,45 @@ import (
 
 // Parse a SelectorsGroup from Tokenizer t.
 func ParseSelector(t Tokenizer) (SelectorsGroup, error) {
-	p := &selectorParser{tokenizer: t}
+	p := &selectorParser{tokenizer: withoutComments(t)}
 	return p.parseSelectorList()
 }
 
+// Parse a SelectorsGroup from Tokenizer t resolving namespace prefixes using
+// the given map of prefixes to namespace URLs, as declared by @namespace rules.
+// The default namespace, if any, is mapped by the empty prefix.
+// See http://www.w3.org/TR/css3-namespace/#declaration
+func ParseSelectorWithNamespaces(t Tokenizer, namespaces map[string]string) (SelectorsGroup, error) {
+	return ParseSelectorWithOptions(t, ParserOptions{Namespaces: namespaces})
+}
+
 // Parse A SelectorsGroup from the string s.
 func ParseSelectorFromString(s string) (SelectorsGroup, error) {
 	return ParseSelector(NewTokenizer(s))
 }
 
+// Represents the options used when parsing selectors.
+type ParserOptions struct {
+	// The declared namespace URLs keyed by prefix, see ParseSelectorWithNamespaces.
+	Namespaces map[string]string
+	// The known pseudo classes and pseudo elements. Any pseudo class or pseudo element
+	// is accepted if nil, otherwise unknown ones are syntax errors.
+	Registry *Registry
+}
+
+// Parse a SelectorsGroup from Tokenizer t using the given options.
+func ParseSelectorWithOptions(t Tokenizer, o ParserOptions) (SelectorsGroup, error) {
+	p := &selectorParser{tokenizer: withoutComments(t), namespaces: o.Namespaces, registry: o.Registry}
+	return p.parseSelectorList()
+}
+
 // Selector parser state.
 type selectorParser struct {
-	tokenizer Tokenizer // Tokenizer used when parsing.
-	saved     Token     // Possibly saved token.
+	tokenizer  Tokenizer         // Tokenizer used when parsing.
+	saved      Token             // Possibly saved token.
+	namespaces map[string]string // Declared namespaces keyed by prefix.
+	registry   *Registry         // Known pseudo classes and pseudo elements, nil to accept all.
+	nested     bool              // If parsing the arguments of a functional pseudo class.
 }
 
 // Parse a selector list.
@@ -44,7 +70,7 @@ func (p *selectorParser) parseSelectorList() (SelectorsGroup, error) {
 		}
 
 		if typ != Comma {
-			return nil, expected(",", tk)
+			return nil, p.expected(tk, ",")
 		}
 
 		s, err = p.parseSelector()
@@ -80,23 +106,12 @@ func (p *selectorParser) parseSelector() (*Selector, error) {
 			break
 		}
 
-		var c Combinator = -1
-		if typ == Delim {
-			switch tk.String() {
-			case ">":
-				c = Child
-			case "+":
-				c = NextSibling
-			case "~":
-				c = LaterSibling
-			}
-		}
-
-		if c == -1 {
+		c, ok := combinator(tk)
+		if !ok {
 			if skipped {
 				c = Descendant
 			} else {
-				return nil, expected("one of ' ', '>', '+', '~'", tk)
+				return nil, p.expected(tk, "' '", "'>'", "'+'", "'~'")
 			}
 
 			p.saved = tk
@@ -129,20 +144,28 @@ func (p *selectorParser) parseSelector() (*Selector, error) {
 // indicating if the last (or only) simple selector in the sequence is a pseudo element.
 // See http://www.w3.org/TR/selectors/#sequence
 func (p *selectorParser) parseSimpleSelectors() ([]SimpleSelector, *PseudoElementSelector, error) {
-	pos := p.tokenizer.Position()
+	start := p.position()
 
 	var ss []SimpleSelector
 	var pseudoElement *PseudoElementSelector
 
 	empty := true
-	tagName, found := p.parseName()
-	if found && tagName != "*" {
-		ss = append(ss, NewLocalNameSelector(tagName))
+	s, found, err := p.parseName()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if found && (s.Name != "*" || s.Namespace != nil) {
+		ss = append(ss, s)
 		empty = false
+	} else if !found && s.Namespace != nil && !p.nested {
+		// A default namespace applies to an omitted universal selector as well,
+		// except within the selector list arguments of pseudo classes.
+		ss = append(ss, s)
 	}
 
 	for {
-		s, err := p.parseOneSimpleSelector(false)
+		s, err := p.parseOneSimpleSelector()
 		if err != nil {
 			return nil, nil, err
 		}
@@ -162,51 +185,116 @@ func (p *selectorParser) parseSimpleSelectors() ([]SimpleSelector, *PseudoElemen
 	}
 
 	if empty && !found {
-		return nil, nil, fmt.Errorf("No simple selectors found at position %d", pos)
+		// The token that couldn't be parsed has been saved.
+		return nil, nil, &SyntaxError{Msg: "No simple selectors found", Start: start, End: p.tokenizer.Position(), Token: p.saved}
 	}
 
 	return ss, pseudoElement, nil
 }
 
-// Parse the name of an element.
-// Returns a name and a boolean indicating if a type selector was found or not.
+// Parse the possibly namespace prefixed name of an element.
+// Returns a type selector and a boolean indicating if a type selector was found or not.
+// If no type selector was found a universal selector in the default namespace is returned.
 // See http://www.w3.org/TR/selectors/#type-selectors and http://www.w3.org/TR/selectors/#universal-selector
-func (p *selectorParser) parseName() (string, bool) {
+func (p *selectorParser) parseName() (*LocalNameSelector, bool, error) {
 	tk, _ := p.skipWhitespace()
-	switch tk.Type() {
-	case Delim:
-		if tk.String() == "*" {
-			return "*", true
+	prefix, name, found, err := p.parseQualifiedName(tk, true)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !found {
+		p.saved = tk
+		name = "*"
+	}
+
+	s := NewLocalNameSelector(name)
+	if prefix == nil {
+		if url, ok := p.namespaces[""]; ok {
+			s.Namespace = &Namespace{URL: url, Default: true}
 		}
-	case Ident:
-		return tk.String(), true
+	} else if s.Namespace, err = p.resolveNamespace(*prefix, tk); err != nil {
+		return nil, false, err
 	}
 
-	p.saved = tk
-	return "*", false
+	return s, found, nil
+}
+
+// Parse a possibly namespace prefixed name starting with the token tk.
+// Returns the prefix (nil if none was given), the name and a boolean indicating if a name was found or not.
+// The universal selector "*" is only accepted as name if wildcard is true.
+// See http://www.w3.org/TR/css3-namespace/#css-qnames
+func (p *selectorParser) parseQualifiedName(tk Token, wildcard bool) (*string, string, bool, error) {
+	var prefix string
+	switch {
+	case tk.Type() == Ident, isDelim(tk, "*"):
+		prefix = tk.String()
+		tk = p.nextToken()
+		if !isDelim(tk, "|") {
+			p.saved = tk
+			if prefix == "*" && !wildcard {
+				return nil, "", false, p.expected(tk, "attribute name")
+			}
+
+			return nil, prefix, true, nil
+		}
+	case isDelim(tk, "|"):
+		prefix = ""
+	default:
+		return nil, "", false, nil
+	}
+
+	tk = p.nextToken()
+	if tk.Type() == Ident || (wildcard && isDelim(tk, "*")) {
+		return &prefix, tk.String(), true, nil
+	}
+
+	return nil, "", false, p.expected(tk, "name after namespace prefix")
+}
+
+// Resolve the namespace prefix using the declared namespaces.
+// Returns an error if the prefix hasn't been declared.
+func (p *selectorParser) resolveNamespace(prefix string, tk Token) (*Namespace, error) {
+	switch prefix {
+	case "*", "":
+		return &Namespace{Prefix: prefix}, nil
+	}
+
+	url, ok := p.namespaces[prefix]
+	if !ok {
+		return nil, &SyntaxError{
+			Msg:   fmt.Sprintf("Undeclared namespace prefix %q", prefix),
+			Start: tk.Position(),
+			End:   p.tokenizer.Position(),
+			Token: tk,
+		}
+	}
+
+	return &Namespace{Prefix: prefix, URL: url}, nil
 }
 
 // Parse one simple selector (excluding the type selector).
 // Returns (nil, nil) if no SimpleSelector can be parsed, error
 // on failure parsing a simple selector.
 // See http://www.w3.org/TR/selectors/#simple-selectors
-func (p *selectorParser) parseOneSimpleSelector(insideNegation bool) (SimpleSelector, error) {
+func (p *selectorParser) parseOneSimpleSelector() (SimpleSelector, error) {
 	tk := p.nextToken()
 	switch tk.Type() {
 	case Hash:
-		h := tk.(*HashToken)
-		return NewAttributeSelector(Equals, "id", h.Value), nil
+		s := NewAttributeSelector(Equals, "id", tk.(*HashToken).Value)
+		s.Shorthand = true
+		return s, nil
 	case Delim:
 		if tk.String() == "." {
 			tk = p.nextToken()
 			if tk.Type() == Ident {
-				return NewAttributeSelector(Includes, "class", tk.String()), nil
+				s := NewAttributeSelector(Includes, "class", tk.String())
+				s.Shorthand = true
+				return s, nil
 			} else {
-				return nil, expected("class value", tk)
+				return nil, p.expected(tk, "class value")
 			}
 		}
-
-		return nil, expected(".", tk)
 	case LeftSquareBracket:
 		return p.parseAttribute()
 	case Colon:
@@ -218,17 +306,21 @@ func (p *selectorParser) parseOneSimpleSelector(insideNegation bool) (SimpleSele
 			case "first-line", "first-letter", "before", "after":
 				return NewPseudoElementSelector(v), nil
 			default:
-				return NewPseudoClassSelector(v), nil
+				return p.parsePseudoClass(tk)
 			}
 		case Colon:
 			tk = p.nextToken()
 			if tk.Type() != Ident {
-				return nil, expected("pseudo element value", tk)
+				return nil, p.expected(tk, "pseudo element value")
+			}
+
+			if p.registry != nil && !p.registry.hasPseudoElement(tk.String()) {
+				return nil, p.unknown(tk, "pseudo element ::%s")
 			}
 
 			return NewPseudoElementSelector(tk.String()), nil
 		case Function:
-			return p.parseFunctionalPseudoClass(tk.String(), insideNegation)
+			return p.parseFunctionalPseudoClass(tk)
 		}
 	}
 
@@ -240,14 +332,25 @@ func (p *selectorParser) parseOneSimpleSelector(insideNegation bool) (SimpleSele
 // See http://www.w3.org/TR/selectors/#attribute-selectors
 func (p *selectorParser) parseAttribute() (*AttributeSelector, error) {
 	tk, _ := p.skipWhitespace()
-	if tk.Type() != Ident {
-		return nil, expected("attribute name", tk)
+	prefix, name, found, err := p.parseQualifiedName(tk, false)
+	if err != nil {
+		return nil, err
+	} else if !found {
+		return nil, p.expected(tk, "attribute name")
+	}
+
+	var ns *Namespace
+	if prefix != nil {
+		if ns, err = p.resolveNamespace(*prefix, tk); err != nil {
+			return nil, err
+		}
 	}
 
-	name := tk.String()
 	tk, _ = p.skipWhitespace()
 	if tk.Type() == RightSquareBracket {
-		return NewAttributeSelector(Exists, name, ""), nil
+		s := NewAttributeSelector(Exists, name, "")
+		s.Namespace = ns
+		return s, nil
 	}
 
 	var match AttributeMatch
@@ -266,7 +369,7 @@ func (p *selectorParser) parseAttribute() (*AttributeSelector, error) {
 		if tk.String() == "=" {
 			match = Equals
 		} else {
-			return nil, expected("=", tk)
+			return nil, p.expected(tk, "=")
 		}
 	}
 
@@ -275,74 +378,349 @@ func (p *selectorParser) parseAttribute() (*AttributeSelector, error) {
 	if tk.Type() == Ident || tk.Type() == String {
 		value = tk.String()
 	} else {
-		return nil, expected("attribute value", tk)
+		return nil, p.expected(tk, "attribute value")
 	}
 
+	var c AttributeCase
 	tk, _ = p.skipWhitespace()
+	if tk.Type() == Ident {
+		switch strings.ToLower(tk.String()) {
+		case "i":
+			c = IgnoreCase
+		case "s":
+			c = SensitiveCase
+		default:
+			return nil, p.expected(tk, "attribute modifier")
+		}
+
+		tk, _ = p.skipWhitespace()
+	}
+
 	if tk.Type() != RightSquareBracket {
-		return nil, expected("]", tk)
+		return nil, p.expected(tk, "]")
+	}
+
+	s := NewAttributeSelector(match, name, value)
+	s.Namespace = ns
+	s.Case = c
+	return s, nil
+}
+
+// Parse a pseudo class from its identifier token.
+func (p *selectorParser) parsePseudoClass(tk Token) (SimpleSelector, error) {
+	if p.registry != nil && p.registry.positional && positionalClasses[toASCIILower(tk.String())] {
+		if p.nested {
+			return nil, p.nestedPositional(tk, ":"+tk.String())
+		}
+
+		return NewPseudoPositionalSelector(tk.String(), 0), nil
+	}
+
+	s := NewPseudoClassSelector(tk.String())
+	if p.registry != nil {
+		f, ok := p.registry.pseudoClass(s.Value)
+		if !ok {
+			return nil, p.unknown(tk, "pseudo class :%s")
+		}
+
+		s.match = f
 	}
 
-	return NewAttributeSelector(match, name, value), nil
+	return s, nil
 }
 
-// Parse a functional pseudo class.
+// Parse a functional pseudo class from its function token.
 // See http://www.w3.org/TR/selectors/#structural-pseudos
-func (p *selectorParser) parseFunctionalPseudoClass(name string, insideNegation bool) (SimpleSelector, error) {
+func (p *selectorParser) parseFunctionalPseudoClass(tk Token) (SimpleSelector, error) {
+	name := tk.String()
 	switch strings.ToLower(name) {
 	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
-		a, b, err := parseNth(p.tokenizer)
+		args, err := p.consumeArguments(false)
 		if err != nil {
 			return nil, err
 		}
 
-		return NewPseudoNthSelector(name, a, b), nil
+		x, err := p.parseAnPlusB(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		// Only :nth-child() and :nth-last-child() take an "of S" clause.
+		// See http://dev.w3.org/csswg/selectors-4/#the-nth-child-pseudo
+		if x.Of != nil && !strings.HasSuffix(strings.ToLower(name), "-child") {
+			return nil, &SyntaxError{Msg: fmt.Sprintf("Unexpected selector list in :%s", name), Start: args[0].Position(), End: args[0].end}
+		}
+
+		s := NewPseudoNthSelector(name, x.A, x.B)
+		s.Of = x.Of
+		return s, nil
 	case "not":
-		if insideNegation {
-			return nil, fmt.Errorf("Error at position %d: negations may not be nested", p.tokenizer.Position())
+		g, err := p.parseSelectorListArgument(false)
+		if err != nil {
+			return nil, err
 		}
 
-		var s *PseudoNegationSelector
-		if tagName, found := p.parseName(); found {
-			s = NewPseudoNegationSelector(NewLocalNameSelector(tagName))
-		} else {
-			ss, err := p.parseOneSimpleSelector(true)
+		return NewPseudoNegationListSelector(g), nil
+	case "is":
+		g, err := p.parseSelectorListArgument(true)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewPseudoIsSelector(g), nil
+	case "where":
+		g, err := p.parseSelectorListArgument(true)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewPseudoWhereSelector(g), nil
+	case "lang":
+		return p.parseLang()
+	case "dir":
+		args, err := p.consumeArguments(false)
+		if err != nil {
+			return nil, err
+		}
+
+		start := args[0].Position()
+		tk := skipWhitespace(args[0])
+		if tk.Type() != Ident || !atEnd(args[0]) {
+			return nil, &SyntaxError{Msg: "Invalid :dir() argument", Start: start, End: args[0].end, Token: tk}
+		}
+
+		return NewPseudoDirSelector(tk.String()), nil
+	case "has":
+		args, err := p.consumeArguments(true)
+		if err != nil {
+			return nil, err
+		}
+
+		var selectors []*RelativeSelector
+		for _, arg := range args {
+			s, err := p.parseRelativeSelector(arg)
 			if err != nil {
 				return nil, err
-			} else if ss == nil {
-				// The failing token was saved when parseOneSimpleSelector didn't find a selector.
-				return nil, expected("simple selector", p.nextToken())
 			}
 
-			s = NewPseudoNegationSelector(ss)
+			selectors = append(selectors, s)
 		}
 
-		tk, _ := p.skipWhitespace()
-		if tk.Type() != RightParen {
-			return nil, expected(")", tk)
+		return NewPseudoHasSelector(selectors), nil
+	default:
+		if p.registry != nil && p.registry.positional && positionalFunctions[strings.ToLower(name)] {
+			if p.nested {
+				return nil, p.nestedPositional(tk, ":"+name+"()")
+			}
+
+			return p.parsePositionalFunction(name)
 		}
 
-		return s, nil
-	default:
-		pos := p.tokenizer.Position()
+		var f *pseudoFunction
+		if p.registry != nil {
+			if f = p.registry.pseudoFunction(name); f == nil {
+				return nil, p.unknown(tk, "pseudo class :%s()")
+			}
+		}
+
+		args, err := p.consumeArguments(false)
+		if err != nil {
+			return nil, err
+		}
 
 		var b bytes.Buffer
-		for {
-			tk := p.nextToken()
-			typ := tk.Type()
-			if typ == EOF {
-				return nil, fmt.Errorf("EOF in function expression starting at position %d", pos)
-			} else if typ == RightParen {
-				break
-			} else {
-				b.WriteString(tk.String())
+		for _, x := range args[0].tokens {
+			b.WriteString(x.String())
+		}
+
+		s := NewPseudoFunctionSelector(name, b.String())
+		s.tokens = args[0].tokens
+		start, end := args[0].Position(), args[0].end
+		s.Values = trimWhitespace(ParseComponentValues(args[0]))
+		if f != nil {
+			if s.Data, err = f.parse(s.Values); err != nil {
+				if _, ok := err.(*SyntaxError); ok {
+					return nil, err
+				}
+
+				return nil, &SyntaxError{Msg: fmt.Sprintf("Invalid arguments of :%s(): %s", name, err), Start: start, End: end}
+			}
+
+			s.match = f.match
+		}
+
+		return s, nil
+	}
+}
+
+// Parse the comma separated language ranges of :lang(), each one an identifier or a string.
+// See http://dev.w3.org/csswg/selectors-4/#the-lang-pseudo
+func (p *selectorParser) parseLang() (SimpleSelector, error) {
+	args, err := p.consumeArguments(true)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []string
+	for _, arg := range args {
+		start := arg.Position()
+		tk := skipWhitespace(arg)
+		if (tk.Type() != Ident && tk.Type() != String) || !atEnd(arg) {
+			return nil, &SyntaxError{Msg: "Invalid :lang() argument", Start: start, End: arg.end, Token: tk}
+		}
+
+		ranges = append(ranges, tk.String())
+	}
+
+	s := NewPseudoLangSelector(ranges)
+	if p.registry != nil {
+		s.defaultLanguage = p.registry.defaultLanguage
+	}
+
+	return s, nil
+}
+
+// Parse the index argument of a jQuery positional functional pseudo class.
+func (p *selectorParser) parsePositionalFunction(name string) (SimpleSelector, error) {
+	args, err := p.consumeArguments(false)
+	if err != nil {
+		return nil, err
+	}
+
+	start := args[0].Position()
+	tk := skipWhitespace(args[0])
+	n, ok := tk.(*NumberToken)
+	if !ok || !n.Integer || !atEnd(args[0]) {
+		return nil, &SyntaxError{Msg: fmt.Sprintf("Invalid index argument of :%s()", name), Start: start, End: args[0].end}
+	}
+
+	return NewPseudoPositionalSelector(name, int(n.Int)), nil
+}
+
+// Parse the selector list argument of a functional pseudo class up to and including the closing parenthesis.
+// Selectors that fail to parse are dropped from a forgiving selector list instead of failing the whole list.
+// See http://dev.w3.org/csswg/selectors-4/#typedef-forgiving-selector-list
+func (p *selectorParser) parseSelectorListArgument(forgiving bool) (SelectorsGroup, error) {
+	args, err := p.consumeArguments(true)
+	if err != nil {
+		return nil, err
+	}
+
+	var g SelectorsGroup
+	for _, arg := range args {
+		s, err := p.parseNestedSelector(arg)
+		if err != nil {
+			if e, ok := err.(*SyntaxError); ok && forgiving && !e.unforgiving {
+				continue
+			}
+
+			return nil, err
+		}
+
+		g = append(g, s)
+	}
+
+	return g, nil
+}
+
+// Parse a selector nested in a functional pseudo class from the given list of tokens.
+func (p *selectorParser) parseNestedSelector(l *tokenList) (*Selector, error) {
+	n := &selectorParser{
+		tokenizer:  l,
+		namespaces: p.namespaces,
+		registry:   p.registry,
+		nested:     true,
+	}
+
+	start := n.position()
+	s, err := n.parseSelector()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.PseudoElement != nil {
+		return nil, &SyntaxError{
+			Msg:   fmt.Sprintf("Unexpected pseudo element %s in selector list", s.PseudoElement),
+			Start: start,
+			End:   n.position(),
+		}
+	}
+
+	if tk, _ := n.skipWhitespace(); tk.Type() != EOF {
+		return nil, n.expected(tk, "end of selector")
+	}
+
+	return s, nil
+}
+
+// Parse a relative selector nested in a functional pseudo class from the given list of tokens.
+// See http://dev.w3.org/csswg/selectors-4/#relative
+func (p *selectorParser) parseRelativeSelector(l *tokenList) (*RelativeSelector, error) {
+	c := Descendant
+	for i, tk := range l.tokens {
+		if tk.Type() == Whitespace {
+			continue
+		}
+
+		if x, ok := combinator(tk); ok {
+			c = x
+			l.tokens = l.tokens[i+1:]
+		}
+
+		break
+	}
+
+	s, err := p.parseNestedSelector(l)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RelativeSelector{c, s}, nil
+}
+
+// Consumes the arguments of a functional pseudo class up to and including the closing parenthesis.
+// Returns the tokens of each comma separated argument, or the tokens of all arguments if split is false.
+func (p *selectorParser) consumeArguments(split bool) ([]*tokenList, error) {
+	start := p.position()
+
+	var args []*tokenList
+	var blocks []TokenType // The closing token types of the currently open blocks.
+	arg := &tokenList{}
+	for {
+		tk := p.nextToken()
+		typ := tk.Type()
+		switch {
+		case typ == EOF:
+			return nil, eofInFunction(start, tk)
+		case typ == Function || typ == LeftParen || typ == LeftSquareBracket || typ == LeftCurlyBracket:
+			blocks = append(blocks, mirror(typ))
+		case len(blocks) > 0:
+			if typ == blocks[len(blocks)-1] {
+				blocks = blocks[:len(blocks)-1]
 			}
+		case typ == RightParen:
+			arg.end = tk.Position()
+			return append(args, arg), nil
+		case typ == Comma && split:
+			arg.end = tk.Position()
+			args = append(args, arg)
+			arg = &tokenList{}
+			continue
 		}
 
-		return NewPseudoFunctionSelector(name, b.String()), nil
+		arg.tokens = append(arg.tokens, tk)
 	}
 }
 
+// Returns the position of the next token to parse.
+func (p *selectorParser) position() Pos {
+	if p.saved != nil {
+		return p.saved.Position()
+	}
+
+	return p.tokenizer.Position()
+}
+
 // Returns the next token to parse.
 func (p *selectorParser) nextToken() Token {
 	if p.saved != nil {
@@ -368,7 +746,62 @@ func (p *selectorParser) skipWhitespace() (Token, bool) {
 	}
 }
 
-// Returns an error of what was expected and what was unexpectedly found.
-func expected(what string, tk Token) error {
-	return fmt.Errorf("Expected %s at position %d, got %s", what, tk.Position(), tk)
+// Returns the combinator represented by tk and whether tk represents one.
+// Descendant combinators are not represented by a single token.
+func combinator(tk Token) (Combinator, bool) {
+	if tk.Type() == Delim {
+		switch tk.String() {
+		case ">":
+			return Child, true
+		case "+":
+			return NextSibling, true
+		case "~":
+			return LaterSibling, true
+		}
+	}
+
+	return Descendant, false
+}
+
+// Returns whether tk is a delimiter token with the value s.
+func isDelim(tk Token, s string) bool {
+	return tk.Type() == Delim && tk.String() == s
+}
+
+// Returns an error of what was expected and what was unexpectedly found,
+// where tk is the token that was read last.
+func (p *selectorParser) expected(tk Token, what ...string) error {
+	msg := "Expected " + what[0]
+	if len(what) > 1 {
+		msg = "Expected one of " + strings.Join(what, ", ")
+	}
+
+	return &SyntaxError{
+		Msg:      msg,
+		Start:    tk.Position(),
+		End:      p.tokenizer.Position(),
+		Token:    tk,
+		Expected: what,
+	}
+}
+
+// Returns a syntax error for the unknown name of the token tk, described by the format string.
+func (p *selectorParser) unknown(tk Token, format string) error {
+	return &SyntaxError{Msg: "Unknown " + fmt.Sprintf(format, tk.String()), Start: tk.Position(), End: p.tokenizer.Position()}
+}
+
+// Returns a syntax error for the positional pseudo class tk nested in a functional pseudo class.
+// It isn't dropped from a forgiving selector list since the selector is valid, just not supported there.
+func (p *selectorParser) nestedPositional(tk Token, name string) error {
+	return &SyntaxError{
+		Msg:         fmt.Sprintf("Unexpected positional pseudo class %s in selector list", name),
+		Start:       tk.Position(),
+		End:         p.tokenizer.Position(),
+		unforgiving: true,
+	}
+}
+
+// Returns an error for the end of input found in the arguments of a function starting at start.
+func eofInFunction(start Pos, tk Token) error {
+	return &SyntaxError{Msg: "EOF in function expression starting", Start: start, End: tk.Position(), Token: tk}
 }
@@ -0,0 +1,177 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"math/bits"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Represents a set of selectors that are matched against nodes at once.
+// The selectors are indexed by the ID, class or type selector of their rightmost
+// compound selector, in that order of preference, so that only the selectors
+// that may match a node are evaluated against it.
+type SelectorSet struct {
+	f       ElementMatchFunc
+	ids     map[string][]*selectorSetEntry
+	classes map[string][]*selectorSetEntry
+	names   map[string][]*selectorSetEntry
+	others  []*selectorSetEntry
+	entries []*selectorSetEntry
+}
+
+// Represents a selector in a SelectorSet matching a node.
+type SelectorSetMatch struct {
+	Selector *Selector   // The matching selector.
+	Value    interface{} // The value added along with the selector.
+}
+
+type selectorSetEntry struct {
+	index   int // The order in which the selector was added.
+	matcher *Matcher
+	SelectorSetMatch
+}
+
+// Creates and returns a new empty SelectorSet using the callback function
+// f, which may be nil, if the default matching machinery didn't find a match.
+func NewSelectorSet(f SimpleSelectorMatchFunc) *SelectorSet {
+	return NewElementSelectorSet(htmlMatchFunc(f))
+}
+
+// Creates and returns a new empty SelectorSet using the callback function
+// f, which may be nil, if the default matching machinery didn't find a match.
+func NewElementSelectorSet(f ElementMatchFunc) *SelectorSet {
+	return &SelectorSet{
+		f:       f,
+		ids:     make(map[string][]*selectorSetEntry),
+		classes: make(map[string][]*selectorSetEntry),
+		names:   make(map[string][]*selectorSetEntry),
+	}
+}
+
+// Returns the number of selectors in this set.
+func (s *SelectorSet) Len() int {
+	return len(s.entries)
+}
+
+// Adds the selectors in g to this set, each one along with the value v.
+func (s *SelectorSet) Add(g SelectorsGroup, v interface{}) error {
+	var entries []*selectorSetEntry
+	for _, x := range g {
+		m, err := CompileWithElementMatchFunc(SelectorsGroup{x}, s.f)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, &selectorSetEntry{
+			index:            len(s.entries) + len(entries),
+			matcher:          m,
+			SelectorSetMatch: SelectorSetMatch{x, v},
+		})
+	}
+
+	for _, e := range entries {
+		s.entries = append(s.entries, e)
+		if key, ok := selectorSetKey(e.Selector.CompoundSelector, isIDSelector); ok {
+			s.ids[key] = append(s.ids[key], e)
+		} else if key, ok := selectorSetKey(e.Selector.CompoundSelector, isClassSelector); ok {
+			s.classes[key] = append(s.classes[key], e)
+		} else if key, ok := selectorSetNameKey(e.Selector.CompoundSelector); ok {
+			s.names[key] = append(s.names[key], e)
+		} else {
+			s.others = append(s.others, e)
+		}
+	}
+
+	return nil
+}
+
+// Returns the selectors in this set matching the HTML node n,
+// in the order they were added.
+// The root element is matched if n is a document node.
+func (s *SelectorSet) Match(n *html.Node) []SelectorSetMatch {
+	e := htmlElement(n)
+	if e == nil {
+		return nil
+	}
+
+	return s.MatchElement(e)
+}
+
+// Returns the selectors in this set matching the Element e,
+// in the order they were added.
+func (s *SelectorSet) MatchElement(e Element) []SelectorSetMatch {
+	// The candidates are marked by their index in a bit set to evaluate them in order.
+	var buf [16]uint64
+	candidates := buf[:]
+	if size := (len(s.entries) + 63) / 64; size > len(buf) {
+		candidates = make([]uint64, size)
+	}
+
+	markEntries(candidates, s.others)
+	markEntries(candidates, s.names[strings.ToLower(e.LocalName())])
+	for i, c := 0, e.NumAttributes(); i < c; i++ {
+		a := e.Attribute(i)
+		if a.Namespace != "" {
+			continue
+		}
+
+		switch a.Name {
+		case "id":
+			markEntries(candidates, s.ids[a.Value])
+		case "class":
+			for v, j := nextField(a.Value, 0); v != ""; v, j = nextField(a.Value, j) {
+				markEntries(candidates, s.classes[v])
+			}
+		}
+	}
+
+	var r []SelectorSetMatch
+	for i, w := range candidates {
+		for ; w != 0; w &= w - 1 {
+			x := s.entries[i*64+bits.TrailingZeros64(w)]
+			if x.matcher.MatchesElement(e) {
+				r = append(r, x.SelectorSetMatch)
+			}
+		}
+	}
+
+	return r
+}
+
+func markEntries(candidates []uint64, entries []*selectorSetEntry) {
+	for _, e := range entries {
+		candidates[e.index/64] |= 1 << uint(e.index%64)
+	}
+}
+
+// Returns whether s is an ID selector that can be used as a key.
+func isIDSelector(s *AttributeSelector) bool {
+	return s.Match == Equals && s.Name == "id" && s.Namespace == nil && s.Case != IgnoreCase
+}
+
+// Returns the value of the first attribute selector of s for which f returns true.
+func selectorSetKey(s *CompoundSelector, f func(*AttributeSelector) bool) (string, bool) {
+	for _, x := range s.SimpleSelectors {
+		if a, ok := x.(*AttributeSelector); ok && f(a) {
+			return a.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// Returns the lowercased name of the first type selector of s, other than the universal selector.
+func selectorSetNameKey(s *CompoundSelector) (string, bool) {
+	for _, x := range s.SimpleSelectors {
+		if l, ok := x.(*LocalNameSelector); ok && l.Name != "*" {
+			return strings.ToLower(l.Name), true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,160 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestSelectorSet(t *testing.T) {
+	var g SelectorsGroup
+	keys := append([]string{}, `h3:contains('palace')`, `[id=""]`, `[ID=test i]`, `DIV.dialog`, `#scene1 > .dialog.scene`)
+	for k := range testSelectors {
+		keys = append(keys, k)
+	}
+
+	set := NewSelectorSet(containsMatcher)
+	for i, k := range keys {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		if err := set.Add(s, i); err != nil {
+			t.Errorf(`Could not add selector %q (%s)`, k, err)
+		}
+
+		g = append(g, s...)
+	}
+
+	if set.Len() != len(g) {
+		t.Errorf(`Got %v selectors in set, want %v`, set.Len(), len(g))
+	}
+
+	var check func(*html.Node)
+	check = func(n *html.Node) {
+		var want []*Selector
+		for _, s := range g {
+			if MatchesSelector(s, n, containsMatcher) {
+				want = append(want, s)
+			}
+		}
+
+		var r []*Selector
+		for _, m := range set.Match(n) {
+			r = append(r, m.Selector)
+		}
+
+		if !reflect.DeepEqual(r, want) {
+			t.Errorf(`Got %v selectors matching %s, want %v`, SelectorsGroup(r), n.Data, SelectorsGroup(want))
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			check(c)
+		}
+	}
+
+	check(dom)
+}
+
+func TestSelectorSetValues(t *testing.T) {
+	set := NewSelectorSet(nil)
+	for _, x := range [][2]string{{`h2, #test, p`, "a"}, {`.dialog.scene, div`, "b"}, {`div h3`, "c"}} {
+		s, err := ParseSelectorFromString(x[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		set.Add(s, x[1])
+	}
+
+	doc, err := html.Parse(strings.NewReader(`<div id="test" class="dialog scene dialog" ID="x"><h3>x</h3></div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"div": {"#test=a", ".dialog.scene=b", "div=b"},
+		"h3":  {"div h3=c"},
+		"p":   nil,
+	}
+
+	for k, v := range tests {
+		var r []string
+		Traverse(doc, func(n *html.Node) {
+			if n.Data != k {
+				return
+			}
+
+			for _, m := range set.Match(n) {
+				r = append(r, m.Selector.String()+"="+m.Value.(string))
+			}
+		})
+
+		if !reflect.DeepEqual(r, v) {
+			t.Errorf(`Got %q matching %s, want %q`, r, k, v)
+		}
+	}
+}
+
+// Returns selectors resembling a style sheet, most of them not matching any nodes.
+func benchmarkSelectorSetSelectors(b *testing.B) []SelectorsGroup {
+	var r []SelectorsGroup
+	for i := 0; i < 100; i++ {
+		for _, f := range []string{`#id%d`, `.dialog.c%d`, `div.c%[1]d > p`, `h%d + div`, `.scene .x%d:first-child`} {
+			s, err := ParseSelectorFromString(fmt.Sprintf(f, i))
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			r = append(r, s)
+		}
+	}
+
+	return r
+}
+
+func BenchmarkSelectorSet(b *testing.B) {
+	set := NewSelectorSet(nil)
+	for i, s := range benchmarkSelectorSetSelectors(b) {
+		if err := set.Add(s, i); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Traverse(dom, func(n *html.Node) {
+			set.Match(n)
+		})
+	}
+}
+
+func BenchmarkSelectorSetLinear(b *testing.B) {
+	var m []*Matcher
+	for _, s := range benchmarkSelectorSetSelectors(b) {
+		x, err := Compile(s)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		m = append(m, x)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Traverse(dom, func(n *html.Node) {
+			for _, x := range m {
+				x.Matches(n)
+			}
+		})
+	}
+}
@@ -4,6 +4,11 @@
 
 package css
 
+import (
+	"bytes"
+	"strconv"
+)
+
 // CombinatorType identifies the combinator separating sequences of simple selectors.
 // See http://www.w3.org/TR/selectors/#combinators
 type Combinator int
@@ -15,6 +20,20 @@ const (
 	LaterSibling
 )
 
+// Returns the serialization of this combinator.
+func (c Combinator) String() string {
+	switch c {
+	case Child:
+		return ">"
+	case NextSibling:
+		return "+"
+	case LaterSibling:
+		return "~"
+	default:
+		return " "
+	}
+}
+
 // Represents a pointer to the previous CompoundSelector separated by a Combinator.
 type Prev struct {
 	Combinator
@@ -29,6 +48,35 @@ type CompoundSelector struct {
 	*Prev
 }
 
+// Returns the serialization of this compound selector including the previous ones.
+func (s *CompoundSelector) String() string {
+	var b bytes.Buffer
+	s.writeTo(&b, false)
+	return b.String()
+}
+
+// Writes the serialization of this compound selector including the previous ones to b.
+// A sole universal selector is omitted if a pseudo element follows.
+// See http://dev.w3.org/csswg/cssom/#serialize-a-selector
+func (s *CompoundSelector) writeTo(b *bytes.Buffer, pseudoElement bool) {
+	if s.Prev != nil {
+		s.Prev.CompoundSelector.writeTo(b, false)
+		b.WriteByte(' ')
+		if s.Prev.Combinator != Descendant {
+			b.WriteString(s.Prev.Combinator.String())
+			b.WriteByte(' ')
+		}
+	}
+
+	if len(s.SimpleSelectors) == 0 && !pseudoElement {
+		b.WriteByte('*')
+	}
+
+	for _, x := range s.SimpleSelectors {
+		b.WriteString(x.String())
+	}
+}
+
 // Represents a selector.
 // http://www.w3.org/TR/selectors/#selector-syntax
 type Selector struct {
@@ -36,10 +84,37 @@ type Selector struct {
 	PseudoElement    *PseudoElementSelector
 }
 
+// Returns the serialization of this selector.
+// See http://dev.w3.org/csswg/cssom/#serialize-a-selector
+func (s *Selector) String() string {
+	var b bytes.Buffer
+	s.CompoundSelector.writeTo(&b, s.PseudoElement != nil)
+	if s.PseudoElement != nil {
+		b.WriteString(s.PseudoElement.String())
+	}
+
+	return b.String()
+}
+
 // SelectorsGroup represents a group of selectors.
 // See http://www.w3.org/TR/selectors/#grouping
 type SelectorsGroup []*Selector
 
+// Returns the serialization of this group of selectors.
+// See http://dev.w3.org/csswg/cssom/#serialize-a-group-of-selectors
+func (g SelectorsGroup) String() string {
+	var b bytes.Buffer
+	for i, s := range g {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		b.WriteString(s.String())
+	}
+
+	return b.String()
+}
+
 // SimpleSelectorType identifies the type of simple selector.
 type SimpleSelectorType int
 
@@ -56,12 +131,19 @@ const (
 	PseudoNth
 	PseudoNegation
 	PseudoFunction
+	PseudoIs
+	PseudoWhere
+	PseudoHas
+	PseudoPositional
+	PseudoLang
+	PseudoDir
 )
 
 // Represents a simple selector.
 // See http://www.w3.org/TR/selectors/#simple-selectors
 type SimpleSelector interface {
 	Type() SimpleSelectorType // The type of this simple selector.
+	String() string           // The serialization of this simple selector.
 }
 
 // AttributeMatch identifies how to match an attribute.
@@ -77,6 +159,50 @@ const (
 	Hyphens
 )
 
+// Returns the serialization of this attribute match operator.
+// The empty string is returned for Exists.
+func (m AttributeMatch) String() string {
+	switch m {
+	case Equals:
+		return "="
+	case Includes:
+		return "~="
+	case Begins:
+		return "^="
+	case Ends:
+		return "$="
+	case Contains:
+		return "*="
+	case Hyphens:
+		return "|="
+	default:
+		return ""
+	}
+}
+
+// AttributeCase identifies how to compare attribute values.
+// See http://dev.w3.org/csswg/selectors-4/#attribute-case
+type AttributeCase int
+
+const (
+	DefaultCase   AttributeCase = iota // Use the case-sensitivity of the document language.
+	IgnoreCase                         // Compare ASCII case-insensitively, set by the i modifier.
+	SensitiveCase                      // Compare case-sensitively, set by the s modifier.
+)
+
+// Returns the serialization of this attribute case modifier.
+// The empty string is returned for DefaultCase.
+func (c AttributeCase) String() string {
+	switch c {
+	case IgnoreCase:
+		return "i"
+	case SensitiveCase:
+		return "s"
+	default:
+		return ""
+	}
+}
+
 // Represents an attribute selector.
 // An attribute selector will also be used to represent class selectors and ID selectors.
 // See http://www.w3.org/TR/selectors/#attribute-selectors
@@ -84,35 +210,122 @@ type AttributeSelector struct {
 	SimpleSelectorType
 	Match       AttributeMatch // How to match the attribute.
 	Name, Value string         // Attribute name and value.
+	Namespace   *Namespace     // The attribute namespace, nil if no namespace prefix was given.
+	Case        AttributeCase  // How to compare the attribute value.
+	Shorthand   bool           // Whether the selector was written as an ID selector, e.g. #foo, or a class selector, e.g. .foo.
 }
 
 // Creates and returns a new AttributeSelector.
 func NewAttributeSelector(match AttributeMatch, name, value string) *AttributeSelector {
-	return &AttributeSelector{Attribute, match, name, value}
+	return &AttributeSelector{
+		SimpleSelectorType: Attribute,
+		Match:              match,
+		Name:               name,
+		Value:              value,
+	}
+}
+
+// Returns the serialization of this attribute selector.
+// Selectors written as class selectors or ID selectors are serialized using their shorthand notation.
+func (s *AttributeSelector) String() string {
+	var b bytes.Buffer
+	switch {
+	case s.Shorthand && s.Match == Equals && s.Name == "id" && s.Value != "" && s.Namespace == nil && s.Case == DefaultCase:
+		b.WriteByte('#')
+		writeIdentifier(&b, s.Value)
+	case s.Shorthand && s.Match == Includes && s.Name == "class" && s.Value != "" && s.Namespace == nil && s.Case == DefaultCase:
+		b.WriteByte('.')
+		writeIdentifier(&b, s.Value)
+	default:
+		b.WriteByte('[')
+		s.Namespace.writeTo(&b)
+		writeIdentifier(&b, s.Name)
+		if s.Match != Exists {
+			b.WriteString(s.Match.String())
+			writeString(&b, s.Value)
+			if s.Case != DefaultCase {
+				b.WriteByte(' ')
+				b.WriteString(s.Case.String())
+			}
+		}
+
+		b.WriteByte(']')
+	}
+
+	return b.String()
 }
 
 // Represents a type selector.
 // See http://www.w3.org/TR/selectors/#type-selectors
+// The universal selector is represented by the name "*", and is only
+// kept by the parser when it has a namespace.
 type LocalNameSelector struct {
 	SimpleSelectorType
-	Name string // The tag name.
+	Name      string     // The tag name.
+	Namespace *Namespace // The element namespace, nil if any namespace matches.
 }
 
 // Creates and returns a new LocalNameSelector.
 func NewLocalNameSelector(name string) *LocalNameSelector {
-	return &LocalNameSelector{LocalName, name}
+	return &LocalNameSelector{
+		SimpleSelectorType: LocalName,
+		Name:               name,
+	}
+}
+
+// Returns the serialization of this type selector.
+func (s *LocalNameSelector) String() string {
+	var b bytes.Buffer
+	s.Namespace.writeTo(&b)
+	if s.Name == "*" {
+		b.WriteByte('*')
+	} else {
+		writeIdentifier(&b, s.Name)
+	}
+
+	return b.String()
+}
+
+// Represents the namespace of a type selector or an attribute selector.
+// See http://www.w3.org/TR/selectors/#typenmsp and http://www.w3.org/TR/selectors/#attrnmsp
+type Namespace struct {
+	Prefix  string // The prefix, "*" for any namespace and "" for no namespace.
+	URL     string // The namespace URL the prefix was resolved to.
+	Default bool   // If this is the default namespace applied to a type selector without a prefix.
+}
+
+// Writes the serialization of the namespace prefix n followed by a '|' to b.
+// Nothing is written for a nil or default namespace.
+func (n *Namespace) writeTo(b *bytes.Buffer) {
+	if n == nil || n.Default {
+		return
+	}
+
+	if n.Prefix == "*" {
+		b.WriteByte('*')
+	} else {
+		writeIdentifier(b, n.Prefix)
+	}
+
+	b.WriteByte('|')
 }
 
 // Represents a pseudo class selector.
 // See http://www.w3.org/TR/selectors/#pseudo-classes
 type PseudoClassSelector struct {
 	SimpleSelectorType
-	Value string // The value of this selector.
+	Value string               // The value of this selector.
+	match PseudoClassMatchFunc // The match function of a pseudo class registered in a Registry, nil if none.
 }
 
 // Creates and returns a new PseudoClassSelector.
 func NewPseudoClassSelector(value string) *PseudoClassSelector {
-	return &PseudoClassSelector{PseudoClass, value}
+	return &PseudoClassSelector{PseudoClass, value, nil}
+}
+
+// Returns the serialization of this pseudo class selector.
+func (s *PseudoClassSelector) String() string {
+	return ":" + SerializeIdentifier(s.Value)
 }
 
 // Represents a pseudo element selector.
@@ -127,17 +340,41 @@ func NewPseudoElementSelector(value string) *PseudoElementSelector {
 	return &PseudoElementSelector{PseudoElement, value}
 }
 
+// Returns the serialization of this pseudo element selector.
+func (s *PseudoElementSelector) String() string {
+	return "::" + SerializeIdentifier(s.Value)
+}
+
 // Represents a nth-* pseudo class selector.
 // See http://www.w3.org/TR/selectors/#pseudo-classes
 type PseudoNthSelector struct {
 	SimpleSelectorType
-	Name string // The name of this selector.
-	A, B int    // The A and B arguments of this selector.
+	Name string         // The name of this selector.
+	A, B int            // The A and B arguments of this selector.
+	Of   SelectorsGroup // The selectors the counted siblings must match, nil to count all siblings.
 }
 
 // Creates and returns a new PseudoNthSelector.
 func NewPseudoNthSelector(name string, a, b int) *PseudoNthSelector {
-	return &PseudoNthSelector{PseudoNth, name, a, b}
+	return &PseudoNthSelector{PseudoNth, name, a, b, nil}
+}
+
+// Returns the serialization of this nth-* pseudo class selector.
+// The arguments are serialized in their normalized An+B form, followed by the "of S" clause if any.
+// See http://dev.w3.org/csswg/css-syntax/#serializing-anb
+func (s *PseudoNthSelector) String() string {
+	var b bytes.Buffer
+	b.WriteByte(':')
+	writeIdentifier(&b, s.Name)
+	b.WriteByte('(')
+	writeAnPlusB(&b, s.A, s.B)
+	if s.Of != nil {
+		b.WriteString(" of ")
+		b.WriteString(s.Of.String())
+	}
+
+	b.WriteByte(')')
+	return b.String()
 }
 
 // Represents a functional pseudo class selector that is not
@@ -145,22 +382,235 @@ func NewPseudoNthSelector(name string, a, b int) *PseudoNthSelector {
 // See http://www.w3.org/TR/selectors/#w3cselgrammar
 type PseudoFunctionSelector struct {
 	SimpleSelectorType
-	Name, Arguments string // The name and arguments.
+	Name, Arguments string                  // The name and arguments.
+	Values          []ComponentValue        // The arguments as component values, nil unless parsed.
+	Data            interface{}             // The arguments as parsed by a Registry, nil if not registered.
+	match           PseudoFunctionMatchFunc // The match function of a pseudo class registered in a Registry, nil if none.
+	tokens          []Token                 // The argument tokens, nil unless parsed.
 }
 
 // Creates and returns a new PseudoFunctionSelector.
 func NewPseudoFunctionSelector(name, arguments string) *PseudoFunctionSelector {
-	return &PseudoFunctionSelector{PseudoFunction, name, arguments}
+	return &PseudoFunctionSelector{PseudoFunction, name, arguments, nil, nil, nil, nil}
+}
+
+// Returns the serialization of this functional pseudo class selector.
+// The arguments of a parsed selector are serialized from their tokens without leading and trailing whitespace,
+// otherwise they're written as is.
+func (s *PseudoFunctionSelector) String() string {
+	args := s.Arguments
+	if s.tokens != nil {
+		t := s.tokens
+		for len(t) > 0 && t[0].Type() == Whitespace {
+			t = t[1:]
+		}
+
+		for len(t) > 0 && t[len(t)-1].Type() == Whitespace {
+			t = t[:len(t)-1]
+		}
+
+		args = Serialize(t)
+	}
+
+	return ":" + SerializeIdentifier(s.Name) + "(" + args + ")"
+}
+
+// Represents a jQuery positional pseudo class selector, e.g. :first or :eq(2), selecting elements
+// by their index in the set of elements matched by the preceding part of the selector.
+// Positional pseudo classes are only parsed using a Registry with the jQuery extensions registered,
+// and they only match elements when querying, see RegisterJQueryExtensions.
+// See http://api.jquery.com/category/selectors/jquery-selector-extensions/
+type PseudoPositionalSelector struct {
+	SimpleSelectorType
+	Name  string // The name of this selector.
+	Index int    // The index argument of :eq(), :gt() and :lt(), zero for the others.
+}
+
+// Creates and returns a new PseudoPositionalSelector.
+func NewPseudoPositionalSelector(name string, index int) *PseudoPositionalSelector {
+	return &PseudoPositionalSelector{PseudoPositional, name, index}
+}
+
+// Returns the serialization of this positional pseudo class selector.
+func (s *PseudoPositionalSelector) String() string {
+	if positionalFunctions[toASCIILower(s.Name)] {
+		return ":" + SerializeIdentifier(s.Name) + "(" + strconv.Itoa(s.Index) + ")"
+	}
+
+	return ":" + SerializeIdentifier(s.Name)
 }
 
 // Represents a negation pseudo class.
-// See http://www.w3.org/TR/selectors/#negation
+// The negated selectors are given by Selectors, or by Selector if Selectors is nil.
+// See http://dev.w3.org/csswg/selectors-4/#negation
 type PseudoNegationSelector struct {
 	SimpleSelectorType
-	Selector SimpleSelector
+	Selector  SimpleSelector // The negated simple selector if the argument is a single simple selector, nil otherwise.
+	Selectors SelectorsGroup // The negated selectors.
 }
 
-// Creates and returns a new PseudoNegationSelector
+// Creates and returns a new PseudoNegationSelector negating a single simple selector, like in Selectors Level 3.
 func NewPseudoNegationSelector(selector SimpleSelector) *PseudoNegationSelector {
-	return &PseudoNegationSelector{PseudoNegation, selector}
+	return &PseudoNegationSelector{PseudoNegation, selector, SelectorsGroup{simpleSelectorGroupItem(selector)}}
+}
+
+// Creates and returns a new PseudoNegationSelector negating a list of selectors.
+func NewPseudoNegationListSelector(selectors SelectorsGroup) *PseudoNegationSelector {
+	s := &PseudoNegationSelector{PseudoNegation, nil, selectors}
+	if len(selectors) == 1 && selectors[0].PseudoElement == nil {
+		if c := selectors[0].CompoundSelector; c.Prev == nil && len(c.SimpleSelectors) == 1 {
+			s.Selector = c.SimpleSelectors[0]
+		}
+	}
+
+	return s
+}
+
+// Returns the negated selectors.
+func (s *PseudoNegationSelector) group() SelectorsGroup {
+	if s.Selectors == nil && s.Selector != nil {
+		return SelectorsGroup{simpleSelectorGroupItem(s.Selector)}
+	}
+
+	return s.Selectors
+}
+
+// Returns the serialization of this negation pseudo class selector.
+func (s *PseudoNegationSelector) String() string {
+	return ":not(" + s.group().String() + ")"
+}
+
+// Returns a selector consisting of the simple selector s only.
+func simpleSelectorGroupItem(s SimpleSelector) *Selector {
+	return &Selector{CompoundSelector: &CompoundSelector{SimpleSelectors: []SimpleSelector{s}}}
+}
+
+// Represents a matches-any pseudo class.
+// See http://dev.w3.org/csswg/selectors-4/#matches
+type PseudoIsSelector struct {
+	SimpleSelectorType
+	Selectors SelectorsGroup // The selectors of which any must match.
+}
+
+// Creates and returns a new PseudoIsSelector
+func NewPseudoIsSelector(selectors SelectorsGroup) *PseudoIsSelector {
+	return &PseudoIsSelector{PseudoIs, selectors}
+}
+
+// Returns the serialization of this matches-any pseudo class selector.
+func (s *PseudoIsSelector) String() string {
+	return ":is(" + s.Selectors.String() + ")"
+}
+
+// Represents a specificity-adjustment pseudo class.
+// See http://dev.w3.org/csswg/selectors-4/#zero-matches
+type PseudoWhereSelector struct {
+	SimpleSelectorType
+	Selectors SelectorsGroup // The selectors of which any must match.
+}
+
+// Creates and returns a new PseudoWhereSelector
+func NewPseudoWhereSelector(selectors SelectorsGroup) *PseudoWhereSelector {
+	return &PseudoWhereSelector{PseudoWhere, selectors}
+}
+
+// Returns the serialization of this specificity-adjustment pseudo class selector.
+func (s *PseudoWhereSelector) String() string {
+	return ":where(" + s.Selectors.String() + ")"
+}
+
+// Represents a relational pseudo class.
+// See http://dev.w3.org/csswg/selectors-4/#relational
+type PseudoHasSelector struct {
+	SimpleSelectorType
+	Selectors []*RelativeSelector // The relative selectors of which any must match.
+}
+
+// Creates and returns a new PseudoHasSelector
+func NewPseudoHasSelector(selectors []*RelativeSelector) *PseudoHasSelector {
+	return &PseudoHasSelector{PseudoHas, selectors}
+}
+
+// Returns the serialization of this relational pseudo class selector.
+func (s *PseudoHasSelector) String() string {
+	var b bytes.Buffer
+	b.WriteString(":has(")
+	for i, x := range s.Selectors {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		b.WriteString(x.String())
+	}
+
+	b.WriteByte(')')
+	return b.String()
+}
+
+// Represents a relative selector, i.e. a selector that is anchored to an element
+// using a leading combinator. A missing combinator represents a descendant combinator.
+// See http://dev.w3.org/csswg/selectors-4/#relative
+type RelativeSelector struct {
+	Combinator Combinator // The combinator relating the anchor element to the selector.
+	Selector   *Selector  // The selector.
+}
+
+// Returns the serialization of this relative selector.
+func (s *RelativeSelector) String() string {
+	if s.Combinator == Descendant {
+		return s.Selector.String()
+	}
+
+	return s.Combinator.String() + " " + s.Selector.String()
+}
+
+// Represents a language pseudo class selector.
+// See http://dev.w3.org/csswg/selectors-4/#the-lang-pseudo
+type PseudoLangSelector struct {
+	SimpleSelectorType
+	Ranges          []string // The language ranges, e.g. "en" or "*-CH".
+	defaultLanguage string   // The language of elements without a language, as set by Registry.SetDefaultLanguage.
+}
+
+// Creates and returns a new PseudoLangSelector.
+func NewPseudoLangSelector(ranges []string) *PseudoLangSelector {
+	return &PseudoLangSelector{PseudoLang, ranges, ""}
+}
+
+// Returns the serialization of this language pseudo class selector.
+// A language range is serialized as a string unless it starts with a letter.
+func (s *PseudoLangSelector) String() string {
+	var b bytes.Buffer
+	b.WriteString(":lang(")
+	for i, r := range s.Ranges {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		if len(r) > 0 && (r[0] >= 'a' && r[0] <= 'z' || r[0] >= 'A' && r[0] <= 'Z') {
+			writeIdentifier(&b, r)
+		} else {
+			b.WriteString(SerializeString(r))
+		}
+	}
+
+	b.WriteByte(')')
+	return b.String()
+}
+
+// Represents a directionality pseudo class selector.
+// See http://dev.w3.org/csswg/selectors-4/#the-dir-pseudo
+type PseudoDirSelector struct {
+	SimpleSelectorType
+	Value string // The directionality, ltr or rtl. Other values never match.
+}
+
+// Creates and returns a new PseudoDirSelector.
+func NewPseudoDirSelector(value string) *PseudoDirSelector {
+	return &PseudoDirSelector{PseudoDir, value}
+}
+
+// Returns the serialization of this directionality pseudo class selector.
+func (s *PseudoDirSelector) String() string {
+	return ":dir(" + SerializeIdentifier(s.Value) + ")"
 }
@@ -0,0 +1,269 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testSerializations = map[string]string{
+	`*`:                             `*`,
+	`*.foo`:                         `.foo`,
+	`::before`:                      `::before`,
+	`DIV:first-child`:               `DIV:first-child`,
+	`a > b  +  c ~ d	e`:             `a > b + c ~ d e`,
+	`div, a ,span`:                  `div, a, span`,
+	`#speech5`:                      `#speech5`,
+	`div.dialog.scene`:              `div.dialog.scene`,
+	`[class~=foo]`:                  `[class~="foo"]`,
+	`[id="bar"]`:                    `[id="bar"]`,
+	`[id=""]`:                       `[id=""]`,
+	`[class^=dia]`:                  `[class^="dia"]`,
+	`[data-x|='a"b']`:               `[data-x|="a\"b"]`,
+	`[lang]`:                        `[lang]`,
+	`.\31 23`:                       `.\31 23`,
+	`#\-`:                           `#\-`,
+	`.a\ b`:                         `.a\ b`,
+	`.-\32 x`:                       `.-\32 x`,
+	`:nth-child(odd)`:               `:nth-child(2n+1)`,
+	`:nth-child(even)`:              `:nth-child(2n)`,
+	`:nth-child(+n-3)`:              `:nth-child(n-3)`,
+	`:nth-last-child(-n+ 3)`:        `:nth-last-child(-n+3)`,
+	`:nth-of-type( 5 )`:             `:nth-of-type(5)`,
+	`:nth-last-of-type(0n-2)`:       `:nth-last-of-type(-2)`,
+	`:not(div)`:                     `:not(div)`,
+	`:not(*)`:                       `:not(*)`,
+	`:not( .x )`:                    `:not(.x)`,
+	`p::first-line`:                 `p::first-line`,
+	`p:after`:                       `p::after`,
+	`h3:contains(foo)`:              `h3:contains(foo)`,
+	`:contains("a)")`:               `:contains("a)")`,
+	`:x(a\)b, #c)`:                  `:x(a\)b, #c)`,
+	`div#scene1 div.dialog div`:     `div#scene1 div.dialog div`,
+	`:is(h1,h2)>a`:                  `:is(h1, h2) > a`,
+	`:not(.a, .b>.c)`:               `:not(.a, .b > .c)`,
+	`:where(:not(*|*), x)`:          `:where(:not(*|*), x)`,
+	`:is(a, ::before, 1)`:           `:is(a)`,
+	`:where()`:                      `:where()`,
+	`:IS(:not(:is(a)))`:             `:is(:not(:is(a)))`,
+	`div:has(>img,+ p, ~.x, a b)`:   `div:has(> img, + p, ~ .x, a b)`,
+	`[type=TEXT i]`:                 `[type="TEXT" i]`,
+	`[id=x S]`:                      `[id="x" s]`,
+	`[class~="a"i]`:                 `[class~="a" i]`,
+	`:has(:has(> a))`:               `:has(:has(> a))`,
+	`tr:nth-child(odd of :not(.x))`: `tr:nth-child(2n+1 of :not(.x))`,
+	`:nth-last-child(-n+3 OF a,b)`:  `:nth-last-child(-n+3 of a, b)`,
+	`:LANG(en,"*-CH", 'de-DE')`:     `:lang(en, "*-CH", de-DE)`,
+	`:lang("")`:                     `:lang("")`,
+	`:dir( RTL )`:                   `:dir(RTL)`,
+}
+
+func TestSelectorSerialization(t *testing.T) {
+	for k, v := range testSerializations {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		if r := s.String(); r != v {
+			t.Errorf(`Got %q serializing %q, want %q`, r, k, v)
+		}
+	}
+}
+
+func TestSelectorSerializationRoundTrip(t *testing.T) {
+	check := func(k string) {
+		s1, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			return
+		}
+
+		s2, err := ParseSelectorFromString(s1.String())
+		if err != nil {
+			t.Errorf(`Could not parse serialized selector %q (%s)`, s1.String(), err)
+			return
+		}
+
+		if !reflect.DeepEqual(s1, s2) {
+			t.Errorf(`Serialized selector %q of %q doesn't round trip`, s1.String(), k)
+		}
+	}
+
+	for k := range testSelectors {
+		check(k)
+	}
+
+	for k := range testSerializations {
+		check(k)
+	}
+}
+
+func TestPseudoFunctionSerializationRoundTrip(t *testing.T) {
+	tests := map[string]string{
+		`:contains('a)')`:   `:contains("a)")`,
+		`:contains("a\"b")`: `:contains("a\"b")`,
+		`:x(a\)b)`:          `:x(a\)b)`,
+		`:x( '(' )`:         `:x("(")`,
+		`:x(f('a,b'), 1px)`: `:x(f("a,b"), 1px)`,
+		`:x(#a\:b, 'c\'d')`: `:x(#a\:b, "c'd")`,
+	}
+
+	for k, v := range tests {
+		s1, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		if s1.String() != v {
+			t.Errorf(`Got %q serializing %q, want %q`, s1.String(), k, v)
+		}
+
+		s2, err := ParseSelectorFromString(s1.String())
+		if err != nil {
+			t.Errorf(`Could not parse serialized selector %q of %q (%s)`, s1.String(), k, err)
+			continue
+		}
+
+		x1 := s1[0].CompoundSelector.SimpleSelectors[0].(*PseudoFunctionSelector)
+		x2 := s2[0].CompoundSelector.SimpleSelectors[0].(*PseudoFunctionSelector)
+		if x1.Arguments != x2.Arguments {
+			t.Errorf(`Got arguments %q reparsing %q serialized as %q, want %q`, x2.Arguments, k, s1.String(), x1.Arguments)
+		}
+
+		if s2.String() != v {
+			t.Errorf(`Got %q serializing %q reparsed from %q`, s2.String(), s1.String(), k)
+		}
+	}
+}
+
+func TestSerializeIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"foo":      "foo",
+		"-foo":     "-foo",
+		"-":        `\-`,
+		"--":       "--",
+		"1a":       `\31 a`,
+		"-1a":      `-\31 a`,
+		"a1":       "a1",
+		"a b":      `a\ b`,
+		"a\x00b":   "a�b",
+		"\x7F":     `\7f `,
+		"rêd":      "rêd",
+		"a.b#c":    `a\.b\#c`,
+		"_x-y":     "_x-y",
+		"\x01\x1F": `\1 \1f `,
+	}
+
+	for k, v := range tests {
+		if r := SerializeIdentifier(k); r != v {
+			t.Errorf(`Got %q serializing identifier %q, want %q`, r, k, v)
+		}
+	}
+}
+
+func TestSerializeString(t *testing.T) {
+	tests := map[string]string{
+		"":        `""`,
+		"foo":     `"foo"`,
+		`a"b`:     `"a\"b"`,
+		`a\b`:     `"a\\b"`,
+		"a'b":     `"a'b"`,
+		"a\nb":    `"a\a b"`,
+		"a\x00b":  "\"a�b\"",
+		"foo bar": `"foo bar"`,
+	}
+
+	for k, v := range tests {
+		if r := SerializeString(k); r != v {
+			t.Errorf(`Got %q serializing string %q, want %q`, r, k, v)
+		}
+	}
+}
+
+func TestNamespaceSerialization(t *testing.T) {
+	tests := map[string]string{
+		`svg|rect`:             `svg|rect`,
+		`*|rect`:               `*|rect`,
+		`|rect`:                `|rect`,
+		`svg|*`:                `svg|*`,
+		`*|*`:                  `*|*`,
+		`rect`:                 `rect`,
+		`.foo`:                 `*.foo`,
+		`[xlink|href]`:         `*[xlink|href]`,
+		`[*|href^=x]`:          `*[*|href^="x"]`,
+		`[|class~=a]`:          `*[|class~="a"]`,
+		`:not(svg|a)`:          `*:not(svg|a)`,
+		`svg|a > [xlink|href]`: `svg|a > *[xlink|href]`,
+	}
+
+	namespaces := map[string]string{
+		"":      XHTMLNamespace,
+		"svg":   SVGNamespace,
+		"xlink": XLinkNamespace,
+	}
+
+	for k, v := range tests {
+		s1, err := ParseSelectorWithNamespaces(NewTokenizer(k), namespaces)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		if r := s1.String(); r != v {
+			t.Errorf(`Got %q serializing %q, want %q`, r, k, v)
+		}
+
+		s2, err := ParseSelectorWithNamespaces(NewTokenizer(v), namespaces)
+		if err != nil {
+			t.Errorf(`Could not parse serialized selector %q (%s)`, v, err)
+		} else if !reflect.DeepEqual(s1, s2) {
+			t.Errorf(`Serialized selector %q of %q doesn't round trip`, v, k)
+		}
+	}
+}
+
+func TestLevel3NegationSelector(t *testing.T) {
+	id := NewAttributeSelector(Equals, "id", "scene1")
+	id.Shorthand = true
+	tests := []*PseudoNegationSelector{
+		NewPseudoNegationSelector(id),
+		{SimpleSelectorType: PseudoNegation, Selector: id},
+	}
+
+	for _, x := range tests {
+		s := &Selector{CompoundSelector: &CompoundSelector{SimpleSelectors: []SimpleSelector{NewLocalNameSelector("div"), x}}}
+		if r := s.String(); r != `div:not(#scene1)` {
+			t.Errorf(`Got %q serializing a Level 3 negation, want "div:not(#scene1)"`, r)
+		}
+
+		if r := s.Specificity(); r != (Specificity{1, 0, 1}) {
+			t.Errorf(`Got specificity %v of a Level 3 negation, want {1 0 1}`, r)
+		}
+
+		if r := QueryAll(SelectorsGroup{s}, dom); len(r) != 242 {
+			t.Errorf(`Got %d nodes matching a Level 3 negation, want 242`, len(r))
+		}
+
+		checkCompiledMatching(t, SelectorsGroup{s}, s.String(), dom, nil)
+	}
+
+	g, err := ParseSelectorFromString(`:not(.a)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if x := g[0].CompoundSelector.SimpleSelectors[0].(*PseudoNegationSelector); x.Selector == nil || x.Selector.(*AttributeSelector).Value != "a" {
+		t.Errorf(`Expected the Selector field of :not(.a) to be the class selector, got %#v`, x.Selector)
+	}
+
+	g, _ = ParseSelectorFromString(`:not(.a, .b)`)
+	if x := g[0].CompoundSelector.SimpleSelectors[0].(*PseudoNegationSelector); x.Selector != nil {
+		t.Errorf(`Expected no Selector field of :not(.a, .b), got %#v`, x.Selector)
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Returns s serialized as a CSS identifier.
+// See http://dev.w3.org/csswg/cssom/#serialize-an-identifier
+func SerializeIdentifier(s string) string {
+	var b bytes.Buffer
+	writeIdentifier(&b, s)
+	return b.String()
+}
+
+// Returns s serialized as a CSS string.
+// See http://dev.w3.org/csswg/cssom/#serialize-a-string
+func SerializeString(s string) string {
+	var b bytes.Buffer
+	writeString(&b, s)
+	return b.String()
+}
+
+// Writes s serialized as a CSS identifier to b.
+func writeIdentifier(b *bytes.Buffer, s string) {
+	r := []rune(s)
+	for i, c := range r {
+		switch {
+		case c == 0:
+			b.WriteRune(unicode.ReplacementChar)
+		case (c >= 0x01 && c <= 0x1F) || c == 0x7F:
+			writeCodePoint(b, c)
+		case IsDigit(c) && (i == 0 || (i == 1 && r[0] == '-')):
+			writeCodePoint(b, c)
+		case c == '-' && i == 0 && len(r) == 1:
+			b.WriteString(`\-`)
+		case c >= 0x80 || c == '-' || c == '_' || IsDigit(c) || IsAlpha(c):
+			b.WriteRune(c)
+		default:
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		}
+	}
+}
+
+// Writes s serialized as a CSS string to b.
+func writeString(b *bytes.Buffer, s string) {
+	b.WriteByte('"')
+	for _, c := range s {
+		switch {
+		case c == 0:
+			b.WriteRune(unicode.ReplacementChar)
+		case (c >= 0x01 && c <= 0x1F) || c == 0x7F:
+			writeCodePoint(b, c)
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+
+	b.WriteByte('"')
+}
+
+// Writes c escaped as a code point to b.
+// See http://dev.w3.org/csswg/cssom/#escape-a-character-as-code-point
+func writeCodePoint(b *bytes.Buffer, c rune) {
+	fmt.Fprintf(b, "\\%x ", c)
+}
+
+// Returns the tokens serialized as CSS, such that tokenizing the result returns tokens of the same
+// types and values. Empty comments are inserted between tokens that would otherwise be tokenized
+// differently, e.g. between two identifiers or between a number and a percent sign.
+// See http://www.w3.org/TR/css-syntax-3/#serialization
+func Serialize(tokens []Token) string {
+	var b bytes.Buffer
+	var prev Token
+	for _, tk := range tokens {
+		if prev != nil && needsComment(prev, tk) {
+			b.WriteString("/**/")
+		}
+
+		writeToken(&b, tk, prev)
+		prev = tk
+	}
+
+	return b.String()
+}
+
+// Writes tk serialized as CSS to b, where prev is the token preceding tk or nil if none.
+func writeToken(b *bytes.Buffer, tk Token, prev Token) {
+	switch tk.Type() {
+	case AtKeyword:
+		b.WriteByte('@')
+		writeIdent(b, tk.String())
+	case BadString:
+		// A bad string is ended by the newline of the following whitespace.
+		b.WriteByte('"')
+	case BadUrl:
+		b.WriteString("url(()")
+	case Dimension:
+		d := tk.(*DimensionToken)
+		b.WriteString(d.Value)
+		writeUnit(b, d.Unit)
+	case EOF:
+	case Function:
+		writeIdent(b, tk.String())
+		b.WriteByte('(')
+	case Hash:
+		h := tk.(*HashToken)
+		b.WriteByte('#')
+		if h.ID {
+			writeIdent(b, h.Value)
+		} else {
+			writeName(b, h.Value)
+		}
+	case Ident:
+		writeIdent(b, tk.String())
+	case Percentage:
+		b.WriteString(tk.String())
+		b.WriteByte('%')
+	case String:
+		writeString(b, tk.String())
+	case UnicodeRange:
+		u := tk.(*UnicodeRangeToken)
+		if u.Start == u.End {
+			fmt.Fprintf(b, "U+%X", u.Start)
+		} else {
+			fmt.Fprintf(b, "U+%X-%X", u.Start, u.End)
+		}
+	case URL:
+		b.WriteString("url(")
+		writeURL(b, tk.String())
+		b.WriteByte(')')
+	case Whitespace:
+		if prev != nil && (prev.Type() == BadString || isDelim(prev, "\\")) {
+			// Bad strings and backslash delimiters are followed by a newline.
+			b.WriteByte('\n')
+		} else if s := tk.String(); s != "" {
+			b.WriteString(s)
+		} else {
+			b.WriteByte(' ')
+		}
+	default:
+		b.WriteString(tk.String())
+	}
+}
+
+// Returns whether a comment must be written between the tokens a and b for them to be tokenized the same.
+func needsComment(a, b Token) bool {
+	switch a.Type() {
+	case Ident:
+		return startsIdentOrNumber(b) || b.Type() == CDC || b.Type() == LeftParen ||
+			(strings.EqualFold(a.String(), "u") && isDelim(b, "+"))
+	case AtKeyword, Hash, Dimension:
+		return startsIdentOrNumber(b) || b.Type() == CDC
+	case UnicodeRange:
+		return startsIdentOrNumber(b) || b.Type() == CDC || isDelim(b, "?")
+	case Number:
+		switch b.Type() {
+		case Ident, Function, URL, BadUrl, Number, Percentage, Dimension:
+			return true
+		}
+
+		return isDelim(b, "%")
+	case Whitespace:
+		return b.Type() == Whitespace
+	case Delim:
+		switch a.String() {
+		case "#", "-":
+			return startsIdentOrNumber(b)
+		case "@":
+			switch b.Type() {
+			case Ident, Function, URL, BadUrl:
+				return true
+			}
+
+			return isDelim(b, "-")
+		case ".", "+":
+			switch b.Type() {
+			case Number, Percentage, Dimension:
+				return true
+			}
+		case "/":
+			return isDelim(b, "*") || b.Type() == SubstringMatch
+		case "<":
+			return isDelim(b, "!")
+		case "|":
+			return isDelim(b, "=") || isDelim(b, "|") || b.Type() == Column || b.Type() == DashMatch
+		case "$", "*", "^", "~":
+			return isDelim(b, "=")
+		}
+	}
+
+	return false
+}
+
+// Returns whether tk is an identifier, function, URL, bad URL, numeric token or a hyphen,
+// i.e. a token continuing an identifier or number preceding it.
+func startsIdentOrNumber(tk Token) bool {
+	switch tk.Type() {
+	case Ident, Function, URL, BadUrl, Number, Percentage, Dimension:
+		return true
+	}
+
+	return isDelim(tk, "-")
+}
+
+// Writes s serialized as an identifier to b, escaping the second hyphen of a leading
+// double hyphen which doesn't start an identifier according to the tokenizer.
+func writeIdent(b *bytes.Buffer, s string) {
+	if strings.HasPrefix(s, "--") {
+		b.WriteString(`-\-`)
+		writeName(b, s[2:])
+		return
+	}
+
+	writeIdentifier(b, s)
+}
+
+// Writes s serialized as a name to b, without escaping digits and hyphens at the start of it.
+// See http://www.w3.org/TR/css-syntax-3/#consume-a-name
+func writeName(b *bytes.Buffer, s string) {
+	for _, c := range s {
+		switch {
+		case c == 0:
+			b.WriteRune(unicode.ReplacementChar)
+		case (c >= 0x01 && c <= 0x1F) || c == 0x7F:
+			writeCodePoint(b, c)
+		case IsNameRune(c):
+			b.WriteRune(c)
+		default:
+			b.WriteByte('\\')
+			b.WriteRune(c)
+		}
+	}
+}
+
+// Writes the unit s of a dimension to b, escaping a leading e that would start the exponent of the number.
+func writeUnit(b *bytes.Buffer, s string) {
+	if len(s) > 1 && (s[0] == 'e' || s[0] == 'E') {
+		r := s[1:]
+		if r[0] == '+' || r[0] == '-' {
+			r = r[1:]
+		}
+
+		if r != "" && IsDigit(rune(r[0])) {
+			writeCodePoint(b, rune(s[0]))
+			writeName(b, s[1:])
+			return
+		}
+	}
+
+	writeIdent(b, s)
+}
+
+// Writes s serialized as the value of an unquoted URL to b.
+// See http://www.w3.org/TR/css-syntax-3/#consume-a-url-token
+func writeURL(b *bytes.Buffer, s string) {
+	for _, c := range s {
+		switch {
+		case c == 0:
+			b.WriteRune(unicode.ReplacementChar)
+		case c == '"' || c == '\'' || c == '(' || c == ')' || c == '\\' || IsSpace(c) || IsNonPrintable(c):
+			writeCodePoint(b, c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+}
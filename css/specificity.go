@@ -0,0 +1,128 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import "fmt"
+
+// Represents the specificity of a selector.
+// See http://www.w3.org/TR/selectors/#specificity
+type Specificity struct {
+	A int // The number of ID selectors.
+	B int // The number of class selectors, attribute selectors and pseudo classes.
+	C int // The number of type selectors and pseudo elements.
+}
+
+// Returns -1, 0 or 1 if s is less than, equal to or greater than o.
+func (s Specificity) Compare(o Specificity) int {
+	switch {
+	case s.A != o.A:
+		return sign(s.A - o.A)
+	case s.B != o.B:
+		return sign(s.B - o.B)
+	default:
+		return sign(s.C - o.C)
+	}
+}
+
+// Returns whether s is less than o.
+func (s Specificity) Less(o Specificity) bool {
+	return s.Compare(o) < 0
+}
+
+// Returns the sum of s and o.
+func (s Specificity) Add(o Specificity) Specificity {
+	return Specificity{s.A + o.A, s.B + o.B, s.C + o.C}
+}
+
+func (s Specificity) String() string {
+	return fmt.Sprintf("(%d,%d,%d)", s.A, s.B, s.C)
+}
+
+// Returns the specificity of this selector.
+func (s *Selector) Specificity() Specificity {
+	var r Specificity
+	for cs := s.CompoundSelector; cs != nil; {
+		for _, ss := range cs.SimpleSelectors {
+			r = r.Add(simpleSelectorSpecificity(ss))
+		}
+
+		if cs.Prev == nil {
+			break
+		}
+
+		cs = cs.Prev.CompoundSelector
+	}
+
+	if s.PseudoElement != nil {
+		r.C++
+	}
+
+	return r
+}
+
+// Returns the specificity of the simple selector s.
+func simpleSelectorSpecificity(s SimpleSelector) Specificity {
+	switch x := s.(type) {
+	case *AttributeSelector:
+		// Only ID selectors count as IDs, attribute selectors like [id=foo] don't.
+		if x.Shorthand && x.Match == Equals && x.Name == "id" {
+			return Specificity{A: 1}
+		}
+
+		return Specificity{B: 1}
+	case *LocalNameSelector:
+		if x.Name == "*" {
+			return Specificity{}
+		}
+
+		return Specificity{C: 1}
+	case *PseudoElementSelector:
+		return Specificity{C: 1}
+	case *PseudoNegationSelector:
+		return maxSpecificity(x.group())
+	case *PseudoIsSelector:
+		return maxSpecificity(x.Selectors)
+	case *PseudoWhereSelector:
+		return Specificity{}
+	case *PseudoNthSelector:
+		return Specificity{B: 1}.Add(maxSpecificity(x.Of))
+	case *PseudoHasSelector:
+		var r Specificity
+		for _, x := range x.Selectors {
+			if y := x.Selector.Specificity(); r.Less(y) {
+				r = y
+			}
+		}
+
+		return r
+	default:
+		return Specificity{B: 1}
+	}
+}
+
+// Returns the specificity of the most specific selector in g.
+// See http://dev.w3.org/csswg/selectors-4/#specificity-rules
+func maxSpecificity(g SelectorsGroup) Specificity {
+	var r Specificity
+	for _, s := range g {
+		if x := s.Specificity(); r.Less(x) {
+			r = x
+		}
+	}
+
+	return r
+}
+
+// Returns the sign of i.
+func sign(i int) int {
+	switch {
+	case i < 0:
+		return -1
+	case i > 0:
+		return 1
+	default:
+		return 0
+	}
+}
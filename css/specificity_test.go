@@ -0,0 +1,78 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import "testing"
+
+var testSpecificities = map[string]Specificity{
+	`*`:                          {0, 0, 0},
+	`li`:                         {0, 0, 1},
+	`ul li`:                      {0, 0, 2},
+	`ul ol + li`:                 {0, 0, 3},
+	`h1 + *[rel=up]`:             {0, 1, 1},
+	`ul ol li.red`:               {0, 1, 3},
+	`li.red.level`:               {0, 2, 1},
+	`#x34y`:                      {1, 0, 0},
+	`[id=x34y]`:                  {0, 1, 0},
+	`[id^=x34y]`:                 {0, 1, 0},
+	`:is([id="x"], .y)`:          {0, 1, 0},
+	`#s12:not(FOO)`:              {1, 0, 1},
+	`:not(*)`:                    {0, 0, 0},
+	`:not(.foo)`:                 {0, 1, 0},
+	`div:first-child`:            {0, 1, 1},
+	`div:nth-child(2n+1)`:        {0, 1, 1},
+	`p::first-line`:              {0, 0, 2},
+	`p:before`:                   {0, 0, 2},
+	`::after`:                    {0, 0, 1},
+	`div#scene1 div.dialog div`:  {1, 1, 3},
+	`h3:contains(foo)`:           {0, 1, 1},
+	`:is(#a, .b) p`:              {1, 0, 1},
+	`:where(#a, .b) p`:           {0, 0, 1},
+	`:not(.a, #b, c)`:            {1, 0, 0},
+	`:not(.a .b, c d e)`:         {0, 2, 0},
+	`:is(::before, x)`:           {0, 0, 1},
+	`:is()`:                      {0, 0, 0},
+	`:has(> #a, .b)`:             {1, 0, 0},
+	`li:has(+ li.x)`:             {0, 1, 2},
+	`li:nth-child(2n of #a, .b)`: {1, 1, 1},
+	`:nth-last-child(1 of p)`:    {0, 1, 1},
+}
+
+func TestSpecificity(t *testing.T) {
+	for k, v := range testSpecificities {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		if r := s[0].Specificity(); r != v {
+			t.Errorf(`Got specificity %v for %q, want %v`, r, k, v)
+		}
+	}
+}
+
+func TestSpecificityCompare(t *testing.T) {
+	tests := []struct {
+		a, b Specificity
+		r    int
+	}{
+		{Specificity{0, 0, 0}, Specificity{0, 0, 0}, 0},
+		{Specificity{1, 0, 0}, Specificity{0, 10, 10}, 1},
+		{Specificity{0, 1, 0}, Specificity{0, 0, 10}, 1},
+		{Specificity{0, 1, 2}, Specificity{0, 1, 3}, -1},
+		{Specificity{0, 2, 0}, Specificity{1, 0, 0}, -1},
+	}
+
+	for _, x := range tests {
+		if r := x.a.Compare(x.b); r != x.r {
+			t.Errorf(`Got %v comparing %v to %v, want %v`, r, x.a, x.b, x.r)
+		}
+
+		if l := x.a.Less(x.b); l != (x.r < 0) {
+			t.Errorf(`Got %v for %v less than %v`, l, x.a, x.b)
+		}
+	}
+}
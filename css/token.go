@@ -4,7 +4,10 @@
 
 package css
 
-import "fmt"
+import (
+	"bytes"
+	"fmt"
+)
 
 // TokenType identifies the type of tokens.
 type TokenType int
@@ -47,27 +50,57 @@ const (
 	UnicodeRange
 	URL
 	Whitespace
+	Comment
 )
 
-// Pos represents the token position in the input text.
-type Pos int
+// Pos represents a position in the input text.
+// A CRLF pair, a carriage return, a form feed and a newline all end a line.
+type Pos struct {
+	Offset int // The byte offset in the input.
+	Line   int // The line number, starting at 1.
+	Column int // The column number, starting at 1, counted in the ColumnUnit of the tokenizer.
+}
 
 // Position returns ifself.
 func (p Pos) Position() Pos {
 	return p
 }
 
+// Source represents the source text of a token, i.e. its text in the input exactly as given.
+// The source text of a token spans the input from the byte offset of its position
+// to the byte offset of its position plus the length of the source text.
+type Source string
+
+// SourceText returns itself as a string.
+func (s Source) SourceText() string {
+	return string(s)
+}
+
 // Represents a token returned from the tokenizer.
 type Token interface {
-	Type() TokenType // The type of this token.
-	Position() Pos   // The position of this token.
-	String() string  // The string value of this token.
+	Type() TokenType    // The type of this token.
+	Position() Pos      // The position of this token.
+	SourceText() string // The source text of this token.
+	String() string     // The string value of this token.
+}
+
+// Returns the text the tokens were tokenized from, by concatenating their source text.
+// The whole input is reconstructed from all tokens returned by a tokenizer preserving comments,
+// otherwise the skipped comments are missing.
+func Reconstruct(tokens []Token) string {
+	var b bytes.Buffer
+	for _, tk := range tokens {
+		b.WriteString(tk.SourceText())
+	}
+
+	return b.String()
 }
 
 // Represents the default Token returned from the tokenizer.
 type TextToken struct {
 	TokenType        // The type of this token.
 	Pos              // The position of this token.
+	Source           // The source text of this token.
 	Value     string // The string value of this token.
 }
 
@@ -79,6 +112,7 @@ func (t *TextToken) String() string {
 type UnicodeRangeToken struct {
 	TokenType     // The type of this token.
 	Pos           // The position of this token.
+	Source        // The source text of this token.
 	Start     int // The start of this unicode range token.
 	End       int // The end of this unicode range token.
 }
@@ -91,6 +125,7 @@ func (t *UnicodeRangeToken) String() string {
 type HashToken struct {
 	TokenType        // The type of this token.
 	Pos              // The position of this token.
+	Source           // The source text of this token.
 	Value     string // The string value of this token.
 	ID        bool   // If the type flag is ID.
 }
@@ -101,10 +136,14 @@ func (t *HashToken) String() string {
 
 // Represents a numeric token returned from the tokenizer.
 type NumberToken struct {
-	TokenType        // The type of this token.
-	Pos              // The position of this token.
-	Value     string // The string value of this token.
-	Integer   bool   // If the type flag is INTEGER.
+	TokenType         // The type of this token.
+	Pos               // The position of this token.
+	Source            // The source text of this token.
+	Value     string  // The string value of this token.
+	Integer   bool    // If the type flag is INTEGER.
+	Float     float64 // The numeric value of this token.
+	Int       int64   // The numeric value of this token if the type flag is INTEGER, zero otherwise.
+	Signed    bool    // If the string value starts with a sign character.
 }
 
 func (t *NumberToken) String() string {
@@ -113,11 +152,15 @@ func (t *NumberToken) String() string {
 
 // Represents a dimension token returned from the tokenizer.
 type DimensionToken struct {
-	TokenType        // The type of this token.
-	Pos              // The position of this token.
-	Value     string // The string value of this token.
-	Integer   bool   // If the type flag is INTEGER.
-	Unit      string // The unit of this dimension token.
+	TokenType         // The type of this token.
+	Pos               // The position of this token.
+	Source            // The source text of this token.
+	Value     string  // The string value of this token.
+	Integer   bool    // If the type flag is INTEGER.
+	Float     float64 // The numeric value of this token.
+	Int       int64   // The numeric value of this token if the type flag is INTEGER, zero otherwise.
+	Signed    bool    // If the string value starts with a sign character.
+	Unit      string  // The unit of this dimension token.
 }
 
 func (t *DimensionToken) String() string {
@@ -5,7 +5,10 @@
 package css
 
 import (
+	"io"
+	"math"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -14,23 +17,112 @@ import (
 // A CSS tokenizer according to http://www.w3.org/TR/css-syntax-3/
 type Tokenizer interface {
 	NextToken() Token // Returns the next token.
-	Position() int    // Returns the position in the input where the next token will be consumed.
+	Position() Pos    // Returns the position in the input where the next token will be consumed.
 }
 
 // Regexp used to preprocess the input.
 // See http://www.w3.org/TR/css-syntax-3/#input-preprocessing
 var preprocessRegexp = regexp.MustCompile(`\f|\r\n?`)
 
-// NewTokenizer returns a new Tokenizer for the given input.
+// Returns the preprocessed input s.
+// See http://www.w3.org/TR/css-syntax-3/#input-preprocessing
+func preprocess(s string) string {
+	s = preprocessRegexp.ReplaceAllLiteralString(s, "\n")
+	return strings.Replace(s, "\u0000", string(unicode.ReplacementChar), -1)
+}
+
+// ColumnUnit identifies the unit in which a tokenizer counts the columns of positions.
+type ColumnUnit int
+
+const (
+	RuneColumns  ColumnUnit = iota // Columns are counted in runes.
+	UTF16Columns                   // Columns are counted in UTF-16 code units, e.g. as in JavaScript.
+)
+
+// Represents the options of a tokenizer.
+type TokenizerOptions struct {
+	ColumnUnit ColumnUnit // The unit in which columns are counted.
+
+	// If comments are returned as Comment tokens holding their text instead of being skipped,
+	// and Whitespace tokens hold the whitespace they represent instead of being empty.
+	// The parse functions skip Comment tokens, so they parse the input the same either way.
+	PreserveComments bool
+}
+
+// NewTokenizer returns a new Tokenizer for the given input, using the default options.
 func NewTokenizer(input string) Tokenizer {
-	i := preprocessRegexp.ReplaceAllLiteralString(input, "\n")
+	return NewTokenizerWithOptions(input, TokenizerOptions{})
+}
+
+// NewTokenizerWithOptions returns a new Tokenizer for the given input, using the options o.
+func NewTokenizerWithOptions(input string, o TokenizerOptions) Tokenizer {
 	return &tokenizer{
-		input:     strings.Replace(i, "\u0000", string(unicode.ReplacementChar), -1),
+		input:     input,
 		pos:       0,
 		markedPos: 0,
+		options:   o,
+		start:     startPos,
 	}
 }
 
+// A Tokenizer reading its input from an io.Reader.
+// Only the input of the token being consumed is kept in memory,
+// so the tokens are produced without reading the whole input up front.
+type ReaderTokenizer struct {
+	tokenizer
+}
+
+// NewReaderTokenizer returns a new ReaderTokenizer reading its input from r, using the default options.
+// The tokens, including their positions, are identical to the ones returned
+// from a Tokenizer created by NewTokenizer using the whole input.
+func NewReaderTokenizer(r io.Reader) *ReaderTokenizer {
+	return NewReaderTokenizerWithOptions(r, TokenizerOptions{})
+}
+
+// NewReaderTokenizerWithOptions returns a new ReaderTokenizer reading its input from r, using the options o.
+func NewReaderTokenizerWithOptions(r io.Reader, o TokenizerOptions) *ReaderTokenizer {
+	return &ReaderTokenizer{tokenizer{src: &source{r: r}, options: o, start: startPos}}
+}
+
+// Returns the first error other than io.EOF encountered reading the input.
+// The input is treated as if it ended where an error was encountered.
+func (t *ReaderTokenizer) Err() error {
+	if t.src == nil || t.src.err == io.EOF {
+		return nil
+	}
+
+	return t.src.err
+}
+
+// The minimum number of bytes read from the input of a ReaderTokenizer at once.
+const minReadSize = 4096
+
+// The input of a ReaderTokenizer.
+type source struct {
+	r   io.Reader
+	buf []byte // Buffer used when reading.
+	err error  // The error returned by the reader, if any.
+}
+
+// Reads and returns more input, reading at most n bytes from the reader at once.
+// The empty string is returned when there's no more input.
+func (s *source) read(n int) string {
+	if n < minReadSize {
+		n = minReadSize
+	}
+
+	if len(s.buf) < n {
+		s.buf = make([]byte, n)
+	}
+
+	c := 0
+	for c == 0 && s.err == nil {
+		c, s.err = s.r.Read(s.buf[:n])
+	}
+
+	return string(s.buf[:c])
+}
+
 // Returns whether the given rune matches [a-zA-Z].
 func IsAlpha(r rune) bool {
 	return (r|0x20) >= 'a' && (r|0x20) <= 'z'
@@ -86,9 +178,40 @@ func hexValue(r rune) int {
 
 // A default implementation for Tokenizer.
 type tokenizer struct {
-	input     string // The input string.
-	pos       int    // The current position in the input.
-	markedPos int    // The marked position
+	input     string           // The input string, or the part of it read so far that is still needed.
+	offset    int              // The position in the input of the first byte of the input string.
+	pos       int              // The current position in the input.
+	markedPos int              // The marked position
+	src       *source          // Used to read more input, nil if the whole input is in the input string.
+	options   TokenizerOptions // The options of this tokenizer.
+	start     Pos              // The position of the start of the token being consumed.
+}
+
+// The position of the start of the input.
+var startPos = Pos{Offset: 0, Line: 1, Column: 1}
+
+// Returns the position of the byte offset p in the input, which must not precede the start of the token being consumed.
+func (t *tokenizer) position(p int) Pos {
+	pos := t.start
+	cr := false
+	for _, r := range t.input[pos.Offset-t.offset : p-t.offset] {
+		switch {
+		case r == '\n' && cr:
+			// The newline of a CRLF pair has already been counted.
+		case r == '\n' || r == '\r' || r == '\f':
+			pos.Line++
+			pos.Column = 1
+		case r >= 0x10000 && t.options.ColumnUnit == UTF16Columns:
+			pos.Column += 2
+		default:
+			pos.Column++
+		}
+
+		cr = r == '\r'
+	}
+
+	pos.Offset = p
+	return pos
 }
 
 // EOF rune
@@ -96,7 +219,31 @@ const eofRune = -1
 
 // isEOF returns whether all runes in the input have been consumed.
 func (t *tokenizer) isEOF() bool {
-	return t.pos >= len(t.input)
+	return len(t.remaining(1)) == 0
+}
+
+// Returns the input following the current position, of at least n bytes unless
+// there's less input left.
+func (t *tokenizer) remaining(n int) string {
+	for t.src != nil && len(t.input)-(t.pos-t.offset) < n {
+		// Read at least as much input as is kept to not copy long tokens over and over.
+		s := t.src.read(len(t.input))
+		if s == "" {
+			break
+		}
+
+		t.input += s
+	}
+
+	return t.input[t.pos-t.offset:]
+}
+
+// Discards the input preceding the current position, which must be at the start of a token.
+func (t *tokenizer) discard() {
+	if t.src != nil {
+		t.input = t.input[t.pos-t.offset:]
+		t.offset = t.pos
+	}
 }
 
 // mark marks the current position in the input.
@@ -104,13 +251,29 @@ func (t *tokenizer) mark() {
 	t.markedPos = t.pos
 }
 
-// next consumes and returns the next rune in the input.
+// next consumes and returns the next rune in the input, as if the input had been preprocessed.
+// A CRLF pair is consumed as a single newline, so the position is never in between them.
+// See http://www.w3.org/TR/css-syntax-3/#input-preprocessing
 func (t *tokenizer) next() rune {
-	if t.isEOF() {
+	in := t.remaining(utf8.UTFMax)
+	if len(in) == 0 {
 		return eofRune
 	}
 
-	r, w := utf8.DecodeRuneInString(t.input[t.pos:])
+	r, w := utf8.DecodeRuneInString(in)
+	switch r {
+	case '\r':
+		if len(in) > 1 && in[1] == '\n' {
+			w++
+		}
+
+		r = '\n'
+	case '\f':
+		r = '\n'
+	case 0:
+		r = unicode.ReplacementChar
+	}
+
 	t.pos += w
 	return r
 }
@@ -144,24 +307,53 @@ func (t *tokenizer) reset() {
 	t.pos = t.markedPos
 }
 
-// EOF token.
-var eofToken = tt(EOF, -1, "")
-
 // Implementation of NextToken for tokenizer.
 func (t *tokenizer) NextToken() Token {
-	if t.isEOF() {
-		return eofToken
+	t.start = t.position(t.pos)
+	t.discard()
+	if !t.options.PreserveComments {
+		t.skipComments()
 	}
 
-	t.skipComments()
+	p := t.position(t.pos)
+	tk := t.consumeToken()
+	s := Source(t.text(p.Offset))
+	switch x := tk.(type) {
+	case *TextToken:
+		x.Pos, x.Source = p, s
+	case *HashToken:
+		x.Pos, x.Source = p, s
+	case *NumberToken:
+		x.Pos, x.Source = p, s
+	case *DimensionToken:
+		x.Pos, x.Source = p, s
+	case *UnicodeRangeToken:
+		x.Pos, x.Source = p, s
+	}
+
+	return tk
+}
+
+// Consumes a token at the current position. The position and source text of the token are set by NextToken.
+// See http://www.w3.org/TR/css-syntax-3/#consume-a-token
+func (t *tokenizer) consumeToken() Token {
 	if t.isEOF() {
-		return eofToken
+		return tt(EOF, "")
 	}
 
 	p := t.pos
+	if t.consume("/*") {
+		t.consumeComment()
+		return tt(Comment, preprocess(t.text(p)))
+	}
+
 	n := t.skipSpace()
 	if n > 0 {
-		return tt(Whitespace, p, "")
+		if t.options.PreserveComments {
+			return tt(Whitespace, preprocess(t.text(p)))
+		}
+
+		return tt(Whitespace, "")
 	}
 
 	t.mark()
@@ -174,7 +366,6 @@ func (t *tokenizer) NextToken() Token {
 		if t.isIdentStart() {
 			return &HashToken{
 				TokenType: Hash,
-				Pos:       Pos(p),
 				Value:     t.consumeName(),
 				ID:        true,
 			}
@@ -184,34 +375,33 @@ func (t *tokenizer) NextToken() Token {
 		if IsNameRune(r1) || IsValidEscape(r1, r2) {
 			return &HashToken{
 				TokenType: Hash,
-				Pos:       Pos(p),
 				Value:     t.consumeName(),
 				ID:        false,
 			}
 		}
 
-		return tt(Delim, p, "#")
+		return tt(Delim, "#")
 	case '$':
 		if t.peek() == '=' {
 			t.next()
-			return tt(SuffixMatch, p, "$=")
+			return tt(SuffixMatch, "$=")
 		}
 
-		return tt(Delim, p, "$")
+		return tt(Delim, "$")
 	case '\'':
 		t.reset()
 		return t.consumeStringToken(true)
 	case '(':
-		return tt(LeftParen, p, "(")
+		return tt(LeftParen, "(")
 	case ')':
-		return tt(RightParen, p, ")")
+		return tt(RightParen, ")")
 	case '*':
 		if t.peek() == '=' {
 			t.next()
-			return tt(SubstringMatch, p, "*=")
+			return tt(SubstringMatch, "*=")
 		}
 
-		return tt(Delim, p, "*")
+		return tt(Delim, "*")
 	case '+':
 		t.reset()
 		if t.isNumberStart() {
@@ -219,9 +409,9 @@ func (t *tokenizer) NextToken() Token {
 		}
 
 		t.next()
-		return tt(Delim, p, "+")
+		return tt(Delim, "+")
 	case ',':
-		return tt(Comma, p, ",")
+		return tt(Comma, ",")
 	case '-':
 		t.reset()
 		if t.isNumberStart() {
@@ -233,11 +423,11 @@ func (t *tokenizer) NextToken() Token {
 		}
 
 		if t.consume("-->") {
-			return tt(CDC, p, "-->")
+			return tt(CDC, "-->")
 		}
 
 		t.next()
-		return tt(Delim, p, "-")
+		return tt(Delim, "-")
 	case '.':
 		t.reset()
 		if t.isNumberStart() {
@@ -245,64 +435,64 @@ func (t *tokenizer) NextToken() Token {
 		}
 
 		t.next()
-		return tt(Delim, p, ".")
+		return tt(Delim, ".")
 	case ':':
-		return tt(Colon, p, ":")
+		return tt(Colon, ":")
 	case ';':
-		return tt(Semicolon, p, ";")
+		return tt(Semicolon, ";")
 	case '<':
 		if t.consume("!--") {
-			return tt(CDO, p, "<!--")
+			return tt(CDO, "<!--")
 		}
 
-		return tt(Delim, p, "<")
+		return tt(Delim, "<")
 	case '@':
 		if t.isIdentStart() {
-			return tt(AtKeyword, p, t.consumeName())
+			return tt(AtKeyword, t.consumeName())
 		}
 
-		return tt(Delim, p, "@")
+		return tt(Delim, "@")
 	case '[':
-		return tt(LeftSquareBracket, p, "[")
+		return tt(LeftSquareBracket, "[")
 	case ']':
-		return tt(RightSquareBracket, p, "]")
+		return tt(RightSquareBracket, "]")
 	case '\\':
 		if IsValidEscape('\\', t.peek()) {
 			t.reset()
 			return t.consumeIdentLikeToken()
 		}
 
-		return tt(Delim, p, "\\")
+		return tt(Delim, "\\")
 	case '^':
 		if t.peek() == '=' {
 			t.next()
-			return tt(PrefixMatch, p, "^=")
+			return tt(PrefixMatch, "^=")
 		}
 
-		return tt(Delim, p, "^")
+		return tt(Delim, "^")
 	case '{':
-		return tt(LeftCurlyBracket, p, "{")
+		return tt(LeftCurlyBracket, "{")
 	case '}':
-		return tt(RightCurlyBracket, p, "}")
+		return tt(RightCurlyBracket, "}")
 	case '|':
 		x := t.peek()
 		switch x {
 		case '=':
 			t.next()
-			return tt(DashMatch, p, "|=")
+			return tt(DashMatch, "|=")
 		case '|':
 			t.next()
-			return tt(Column, p, "||")
+			return tt(Column, "||")
 		}
 
-		return tt(Delim, p, "|")
+		return tt(Delim, "|")
 	case '~':
 		if t.peek() == '=' {
 			t.next()
-			return tt(IncludeMatch, p, "~=")
+			return tt(IncludeMatch, "~=")
 		}
 
-		return tt(Delim, p, "~")
+		return tt(Delim, "~")
 	}
 
 	if IsDigit(r) {
@@ -326,12 +516,12 @@ func (t *tokenizer) NextToken() Token {
 		return t.consumeIdentLikeToken()
 	}
 
-	return tt(Delim, p, string(r))
+	return tt(Delim, string(r))
 }
 
 // Implementation of Position for tokenizer.
-func (t *tokenizer) Position() int {
-	return t.pos
+func (t *tokenizer) Position() Pos {
+	return t.position(t.pos)
 }
 
 // Returns whether the tokenizer could match an identifier at the current position.
@@ -374,20 +564,33 @@ func (t *tokenizer) isNumberStart() bool {
 // Skip comments at the current position.
 func (t *tokenizer) skipComments() {
 	for t.consume("/*") {
-		for {
-			r := t.next()
-			if r == eofRune {
-				return
-			}
+		t.consumeComment()
+	}
+}
 
-			if r == '*' && t.peek() == '/' {
-				t.next() // Eat up the '/'
-				return
-			}
+// Consume the rest of a comment.
+// It is assumed that the opening /* has already been consumed.
+// See http://www.w3.org/TR/css-syntax-3/#consume-comments
+func (t *tokenizer) consumeComment() {
+	for {
+		r := t.next()
+		if r == eofRune {
+			return
+		}
+
+		if r == '*' && t.peek() == '/' {
+			t.next() // Eat up the '/'
+			return
 		}
 	}
 }
 
+// Returns the input from the position p, which must not precede the start of the token being consumed,
+// to the current position.
+func (t *tokenizer) text(p int) string {
+	return t.input[p-t.offset : t.pos-t.offset]
+}
+
 // Skip whitespace at the current position. Returns the number of whitespace runes skipped.
 func (t *tokenizer) skipSpace() int {
 	n := 0
@@ -413,9 +616,11 @@ func (t *tokenizer) consumeNumericToken() Token {
 	if t.isIdentStart() {
 		return &DimensionToken{
 			TokenType: Dimension,
-			Pos:       token.Pos,
 			Value:     token.Value,
 			Integer:   token.Integer,
+			Float:     token.Float,
+			Int:       token.Int,
+			Signed:    token.Signed,
 			Unit:      t.consumeName(),
 		}
 	}
@@ -426,7 +631,6 @@ func (t *tokenizer) consumeNumericToken() Token {
 // Consume an ident-like token.
 // See http://www.w3.org/TR/css-syntax-3/#consume-an-ident-like-token
 func (t *tokenizer) consumeIdentLikeToken() Token {
-	p := t.pos
 	name := t.consumeName()
 	typ := Ident
 	if t.peek() == '(' {
@@ -438,7 +642,7 @@ func (t *tokenizer) consumeIdentLikeToken() Token {
 		}
 	}
 
-	return tt(typ, p, name)
+	return tt(typ, name)
 }
 
 // Consume a string token.
@@ -446,7 +650,6 @@ func (t *tokenizer) consumeIdentLikeToken() Token {
 func (t *tokenizer) consumeStringToken(apostrophe bool) *TextToken {
 	var s []rune
 
-	p := t.pos
 	t.next() // Consume the quote
 	for {
 		t.mark()
@@ -457,7 +660,7 @@ func (t *tokenizer) consumeStringToken(apostrophe bool) *TextToken {
 
 		if r == '\n' {
 			t.reset()
-			return tt(BadString, p, "")
+			return tt(BadString, "")
 		}
 
 		if r == '\\' {
@@ -474,7 +677,7 @@ func (t *tokenizer) consumeStringToken(apostrophe bool) *TextToken {
 		}
 	}
 
-	return tt(String, p, string(s))
+	return tt(String, string(s))
 }
 
 // Consume a URL token.
@@ -482,19 +685,16 @@ func (t *tokenizer) consumeStringToken(apostrophe bool) *TextToken {
 // See http://www.w3.org/TR/css-syntax-3/#consume-a-url-token
 func (t *tokenizer) consumeURLToken() *TextToken {
 	t.skipSpace()
-	p := t.pos
 	if t.isEOF() {
-		return tt(URL, p, "")
+		return tt(URL, "")
 	}
 
 	r := t.peek()
 	if r == '\'' || r == '"' {
 		token := t.consumeStringToken(r != '"')
 		if token.TokenType == BadString {
-			p = t.pos
 			t.consumeBadURL()
 			token.TokenType = BadUrl
-			token.Pos = Pos(p)
 			return token
 		} else {
 			t.skipSpace()
@@ -505,14 +705,11 @@ func (t *tokenizer) consumeURLToken() *TextToken {
 				}
 
 				token.TokenType = URL
-				token.Pos = Pos(p)
 				return token
 			}
 
-			p = t.pos
 			t.consumeBadURL()
 			token.TokenType = BadUrl
-			token.Pos = Pos(p)
 			return token
 		}
 	}
@@ -522,7 +719,7 @@ func (t *tokenizer) consumeURLToken() *TextToken {
 	for {
 		r = t.next()
 		if r == ')' || r == eofRune {
-			return tt(URL, p, string(s))
+			return tt(URL, string(s))
 		}
 
 		if IsSpace(r) {
@@ -532,24 +729,21 @@ func (t *tokenizer) consumeURLToken() *TextToken {
 		}
 
 		if spaceSeen {
-			p = t.pos
 			t.consumeBadURL()
-			return tt(BadUrl, p, "")
+			return tt(BadUrl, "")
 		}
 
 		if r == '\'' || r == '"' || r == '(' || IsNonPrintable(r) {
-			p := t.pos
 			t.consumeBadURL()
-			return tt(BadUrl, p, "")
+			return tt(BadUrl, "")
 		}
 
 		if r == '\\' {
 			if IsValidEscape(r, t.peek()) {
 				s = append(s, t.consumeEscape())
 			} else {
-				p = t.pos
 				t.consumeBadURL()
-				return tt(BadUrl, p, "")
+				return tt(BadUrl, "")
 			}
 		} else {
 			s = append(s, r)
@@ -562,7 +756,6 @@ func (t *tokenizer) consumeURLToken() *TextToken {
 // and that the next input rune has been verified to be a hex digit or a ?
 // See http://www.w3.org/TR/css-syntax-3/#consume-a-unicode-range-token
 func (t *tokenizer) consumeUnicodeRangeToken() *UnicodeRangeToken {
-	p := t.pos
 	start, length := 0, 0
 	for IsHexDigit(t.peek()) && length < 6 {
 		start = (start << 4) + hexValue(t.next())
@@ -587,7 +780,6 @@ func (t *tokenizer) consumeUnicodeRangeToken() *UnicodeRangeToken {
 
 		return &UnicodeRangeToken{
 			TokenType: UnicodeRange,
-			Pos:       Pos(p),
 			Start:     start,
 			End:       end,
 		}
@@ -608,7 +800,6 @@ func (t *tokenizer) consumeUnicodeRangeToken() *UnicodeRangeToken {
 
 	return &UnicodeRangeToken{
 		TokenType: UnicodeRange,
-		Pos:       Pos(p),
 		Start:     start,
 		End:       end,
 	}
@@ -670,7 +861,6 @@ func (t *tokenizer) consumeName() string {
 // It is assumed that the current position of the tokenizer represents a number token.
 func (t *tokenizer) consumeNumber() *NumberToken {
 	var s []rune
-	p := t.pos
 	r := t.peek()
 	if r == '+' || r == '-' {
 		s = append(s, t.next())
@@ -702,12 +892,34 @@ func (t *tokenizer) consumeNumber() *NumberToken {
 		}
 	}
 
+	f, n := convertNumber(string(s), i)
 	return &NumberToken{
 		TokenType: Number,
-		Pos:       Pos(p),
 		Value:     string(s),
 		Integer:   i,
+		Float:     f,
+		Int:       n,
+		Signed:    s[0] == '+' || s[0] == '-',
+	}
+}
+
+// Converts the string representation s of a number to its numeric value, and to its
+// integer value if it's an integer. Values out of range are clamped to the closest value in range.
+// See http://www.w3.org/TR/css-syntax-3/#convert-a-string-to-a-number
+func convertNumber(s string, integer bool) (float64, int64) {
+	// The conversion of strconv is exact up to the rounding to the closest float64.
+	f, _ := strconv.ParseFloat(s, 64)
+	if math.IsInf(f, 0) {
+		f = math.Copysign(math.MaxFloat64, f)
 	}
+
+	var n int64
+	if integer {
+		// Out of range integers result in the maximum or minimum int64 along with an error.
+		n, _ = strconv.ParseInt(s, 10, 64)
+	}
+
+	return f, n
 }
 
 // Consume the remnants of a bad URL.
@@ -749,7 +961,7 @@ func (t *tokenizer) isValidExponent() bool {
 
 // Tries to consume s at the current position. Returns true on success.
 func (t *tokenizer) consume(s string) bool {
-	if !t.isEOF() && strings.HasPrefix(t.input[t.pos:], s) {
+	if strings.HasPrefix(t.remaining(len(s)), s) {
 		t.pos += len(s)
 		return true
 	}
@@ -758,10 +970,55 @@ func (t *tokenizer) consume(s string) bool {
 }
 
 // Create a new DefaultToken.
-func tt(typ TokenType, pos int, value string) *TextToken {
+func tt(typ TokenType, value string) *TextToken {
 	return &TextToken{
 		TokenType: typ,
-		Pos:       Pos(pos),
 		Value:     value,
 	}
 }
+
+// Returns a Tokenizer returning the tokens of t except for Comment tokens.
+func withoutComments(t Tokenizer) Tokenizer {
+	return commentSkipper{t}
+}
+
+// A Tokenizer skipping the Comment tokens of another Tokenizer.
+type commentSkipper struct {
+	Tokenizer
+}
+
+// Implementation of NextToken for commentSkipper.
+func (t commentSkipper) NextToken() Token {
+	for {
+		tk := t.Tokenizer.NextToken()
+		if tk.Type() != Comment {
+			return tk
+		}
+	}
+}
+
+// A Tokenizer returning a list of previously consumed tokens followed by an EOF token.
+type tokenList struct {
+	tokens []Token // The remaining tokens.
+	end    Pos     // The position of the EOF token.
+}
+
+// Implementation of NextToken for tokenList.
+func (t *tokenList) NextToken() Token {
+	if len(t.tokens) == 0 {
+		return &TextToken{TokenType: EOF, Pos: t.end}
+	}
+
+	tk := t.tokens[0]
+	t.tokens = t.tokens[1:]
+	return tk
+}
+
+// Implementation of Position for tokenList.
+func (t *tokenList) Position() Pos {
+	if len(t.tokens) == 0 {
+		return t.end
+	}
+
+	return t.tokens[0].Position()
+}
@@ -7,12 +7,30 @@ package css
 import (
 	"encoding/json"
 	"io/ioutil"
+	"math"
 	"reflect"
-	"strconv"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestTokenizerSpecCompliance(t *testing.T) {
+	runTokenizerSpecTests(t, NewTokenizer)
+}
+
+func TestReaderTokenizerSpecCompliance(t *testing.T) {
+	runTokenizerSpecTests(t, func(input string) Tokenizer {
+		return NewReaderTokenizer(iotest.OneByteReader(strings.NewReader(input)))
+	})
+}
+
+func TestPreserveCommentsSpecCompliance(t *testing.T) {
+	runTokenizerSpecTests(t, func(input string) Tokenizer {
+		return withoutComments(NewTokenizerWithOptions(input, TokenizerOptions{PreserveComments: true}))
+	})
+}
+
+func runTokenizerSpecTests(t *testing.T, f func(string) Tokenizer) {
 	data, err := ioutil.ReadFile("testdata/component_value_list.json")
 	if err != nil {
 		t.Fatal("Could not read component_value_list.json")
@@ -25,7 +43,7 @@ func TestTokenizerSpecCompliance(t *testing.T) {
 
 	for i := 0; i < len(arr); i += 2 {
 		input := arr[i].(string)
-		r := tokenize(input, t)
+		r := tokenize(f(input), t)
 		e := arr[i+1].([]interface{})
 		if len(r) != len(e) {
 			t.Errorf(`Expected len %v for test %v, got %v`, len(e), len(r), i)
@@ -41,82 +59,378 @@ func TestTokenizerSpecCompliance(t *testing.T) {
 	}
 }
 
-func tokenize(input string, t *testing.T) []interface{} {
+func tokenize(tokenizer Tokenizer, t *testing.T) []interface{} {
 	var r []interface{}
-
-	tokenizer := NewTokenizer(input)
 	for {
-		var v interface{}
 		tk := tokenizer.NextToken()
-		switch tk.Type() {
-		case EOF:
+		if tk.Type() == EOF {
 			return r
-		case AtKeyword:
-			v = vals("at-keyword", tk.String())
-		case BadString:
-			v = vals("error", "bad-string")
-		case BadUrl:
-			v = vals("error", "bad-url")
-		case Function:
-			v = vals("function", tk.String())
-		case Hash:
-			h := tk.(*HashToken)
-			s := "unrestricted"
-			if h.ID {
-				s = "id"
+		}
+
+		r = append(r, tokenValue(tk, t))
+	}
+}
+
+// Returns the css-parsing-tests representation of tk.
+func tokenValue(tk Token, t *testing.T) interface{} {
+	var v interface{}
+	switch tk.Type() {
+	case AtKeyword:
+		v = vals("at-keyword", tk.String())
+	case BadString:
+		v = vals("error", "bad-string")
+	case BadUrl:
+		v = vals("error", "bad-url")
+	case Function:
+		v = vals("function", tk.String())
+	case Hash:
+		h := tk.(*HashToken)
+		s := "unrestricted"
+		if h.ID {
+			s = "id"
+		}
+
+		v = vals("hash", h.Value, s)
+	case Ident:
+		v = vals("ident", tk.String())
+	case String:
+		v = vals("string", tk.String())
+	case URL:
+		v = vals("url", tk.String())
+	case Whitespace:
+		v = " "
+	case Dimension:
+		d := tk.(*DimensionToken)
+		s := "number"
+		if d.Integer {
+			s = "integer"
+		}
+
+		v = vals("dimension", d.Value, d.Float, s, d.Unit)
+	case Number, Percentage:
+		n := tk.(*NumberToken)
+		s := "number"
+		if n.Integer {
+			s = "integer"
+		}
+
+		x := "number"
+		if n.TokenType == Percentage {
+			x = "percentage"
+		}
+
+		v = vals(x, n.Value, n.Float, s)
+	case UnicodeRange:
+		u := tk.(*UnicodeRangeToken)
+		v = vals("unicode-range", float64(u.Start), float64(u.End))
+	default:
+		v = tk.String()
+	}
+
+	return v
+}
+
+func vals(v ...interface{}) []interface{} {
+	return v
+}
+
+func TestReaderTokenizer(t *testing.T) {
+	inputs := []string{
+		"a\r\nb\rc\fd\n\r\n",
+		"\r\r\n\n\x00x\x00",
+		"url(" + strings.Repeat("x", 3*minReadSize) + ") \"" + strings.Repeat("\\41 ", minReadSize) + "\"",
+		"/* " + strings.Repeat("*", 2*minReadSize) + " */#id{color:red}",
+		"\xff\xe2\x82 \xe2\x82\xac u+1?? 1e3px 10% @media <!-- -->",
+	}
+
+	for _, s := range inputs {
+		readers := map[string]func() Tokenizer{
+			"reader":   func() Tokenizer { return NewReaderTokenizer(strings.NewReader(s)) },
+			"one byte": func() Tokenizer { return NewReaderTokenizer(iotest.OneByteReader(strings.NewReader(s))) },
+			"half":     func() Tokenizer { return NewReaderTokenizer(iotest.HalfReader(strings.NewReader(s))) },
+			"data err": func() Tokenizer { return NewReaderTokenizer(iotest.DataErrReader(strings.NewReader(s))) },
+		}
+
+		for k, f := range readers {
+			t1, t2 := NewTokenizer(s), f()
+			for {
+				if p1, p2 := t1.Position(), t2.Position(); p1 != p2 {
+					t.Errorf(`Got position %v from %s tokenizer, want %v`, p2, k, p1)
+					break
+				}
+
+				tk1, tk2 := t1.NextToken(), t2.NextToken()
+				if !reflect.DeepEqual(tk1, tk2) {
+					t.Errorf(`Got token %#v from %s tokenizer, want %#v`, tk2, k, tk1)
+					break
+				}
+
+				if tk1.Type() == EOF {
+					break
+				}
 			}
 
-			v = vals("hash", h.Value, s)
-		case Ident:
-			v = vals("ident", tk.String())
-		case String:
-			v = vals("string", tk.String())
-		case URL:
-			v = vals("url", tk.String())
-		case Whitespace:
-			v = " "
-		case Dimension:
-			d := tk.(*DimensionToken)
-			s := "number"
-			if d.Integer {
-				s = "integer"
+			if err := t2.(*ReaderTokenizer).Err(); err != nil {
+				t.Errorf(`Got error %v from %s tokenizer`, err, k)
 			}
+		}
+	}
+}
+
+func TestReaderTokenizerError(t *testing.T) {
+	tokenizer := NewReaderTokenizer(iotest.TimeoutReader(strings.NewReader("a b")))
+	for tokenizer.NextToken().Type() != EOF {
+	}
+
+	if err := tokenizer.Err(); err != iotest.ErrTimeout {
+		t.Errorf(`Got error %v, want %v`, err, iotest.ErrTimeout)
+	}
+}
 
-			f, err := strconv.ParseFloat(d.Value, 64)
-			if err != nil {
-				t.Fatal(err)
+func TestReaderTokenizerBufferSize(t *testing.T) {
+	tokenizer := NewReaderTokenizer(strings.NewReader(strings.Repeat("a { b: c }\r\n", 100000)))
+	for tokenizer.NextToken().Type() != EOF {
+		if n := len(tokenizer.input); n > 2*minReadSize {
+			t.Fatalf(`Got %v bytes of buffered input, want at most %v`, n, 2*minReadSize)
+		}
+	}
+}
+
+func TestTokenizerPositions(t *testing.T) {
+	const input = "a {\r\n  b: \U0001F600;/*\r\n*/\f}"
+	want := []Pos{{0, 1, 1}, {1, 1, 2}, {2, 1, 3}, {3, 1, 4}, {7, 2, 3}, {8, 2, 4}, {9, 2, 5}, {10, 2, 6}, {14, 2, 7}, {21, 3, 3}, {22, 4, 1}, {23, 4, 2}}
+	for _, u := range []ColumnUnit{RuneColumns, UTF16Columns} {
+		tokenizers := map[string]Tokenizer{
+			"string": NewTokenizerWithOptions(input, TokenizerOptions{ColumnUnit: u}),
+			"reader": NewReaderTokenizerWithOptions(iotest.OneByteReader(strings.NewReader(input)), TokenizerOptions{ColumnUnit: u}),
+		}
+
+		for k, tokenizer := range tokenizers {
+			var r []Pos
+			for {
+				tk := tokenizer.NextToken()
+				r = append(r, tk.Position())
+				if tk.Type() == EOF {
+					break
+				}
 			}
 
-			v = vals("dimension", d.Value, f, s, d.Unit)
-		case Number, Percentage:
-			n := tk.(*NumberToken)
-			s := "number"
-			if n.Integer {
-				s = "integer"
+			if u == UTF16Columns {
+				// The emoji is a surrogate pair in UTF-16.
+				want[8].Column = 8
 			}
 
-			f, err := strconv.ParseFloat(n.Value, 64)
-			if err != nil {
-				t.Fatal(err)
+			if !reflect.DeepEqual(r, want) {
+				t.Errorf(`Got positions %v from %s tokenizer counting columns in unit %v, want %v`, r, k, u, want)
 			}
+		}
+	}
+}
+
+func TestPreserveComments(t *testing.T) {
+	const input = "a /* x */\r\n\tb/**//*/ c */#d/* e"
+	want := []string{"a", " ", "/* x */", "\n\t", "b", "/**/", "/*/ c */", "#d", "/* e", ""}
+	tokenizers := map[string]Tokenizer{
+		"string": NewTokenizerWithOptions(input, TokenizerOptions{PreserveComments: true}),
+		"reader": NewReaderTokenizerWithOptions(iotest.OneByteReader(strings.NewReader(input)), TokenizerOptions{PreserveComments: true}),
+	}
 
-			x := "number"
-			if n.TokenType == Percentage {
-				x = "percentage"
+	for k, tokenizer := range tokenizers {
+		var r []string
+		for {
+			tk := tokenizer.NextToken()
+			r = append(r, tk.String())
+			if tk.Type() == EOF {
+				break
 			}
 
-			v = vals(x, n.Value, f, s)
-		case UnicodeRange:
-			u := tk.(*UnicodeRangeToken)
-			v = vals("unicode-range", float64(u.Start), float64(u.End))
-		default:
-			v = tk.String()
+			if s := tk.String(); strings.HasPrefix(s, "/*") != (tk.Type() == Comment) {
+				t.Errorf(`Got token %q of type %v from %s tokenizer`, s, tk.Type(), k)
+			}
 		}
 
-		r = append(r, v)
+		if !reflect.DeepEqual(r, want) {
+			t.Errorf(`Got tokens %q from %s tokenizer, want %q`, r, k, want)
+		}
 	}
 }
 
-func vals(v ...interface{}) []interface{} {
-	return v
+func TestReconstruct(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/component_value_list.json")
+	if err != nil {
+		t.Fatal("Could not read component_value_list.json")
+	}
+
+	var arr []interface{}
+	if err := json.Unmarshal(data, &arr); err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := []string{"a /* b */\r\n\x00\f\rc\r", "/* unterminated"}
+	for i := 0; i < len(arr); i += 2 {
+		inputs = append(inputs, arr[i].(string))
+	}
+
+	for _, input := range inputs {
+		tokenizers := map[string]Tokenizer{
+			"string": NewTokenizerWithOptions(input, TokenizerOptions{PreserveComments: true}),
+			"reader": NewReaderTokenizerWithOptions(iotest.OneByteReader(strings.NewReader(input)), TokenizerOptions{PreserveComments: true}),
+		}
+
+		for k, tokenizer := range tokenizers {
+			var tokens []Token
+			for {
+				tk := tokenizer.NextToken()
+				if n := len(Reconstruct(tokens)); tk.Position().Offset != n {
+					t.Errorf(`Got token %q at offset %v from %s tokenizer, want %v`, tk.SourceText(), tk.Position().Offset, k, n)
+				}
+
+				tokens = append(tokens, tk)
+				if tk.Type() == EOF {
+					break
+				}
+			}
+
+			if s := Reconstruct(tokens); s != input {
+				t.Errorf(`Got %q reconstructed from %s tokenizer, want %q`, s, k, input)
+			}
+		}
+	}
+}
+
+func TestSourceText(t *testing.T) {
+	const input = `\31 23 1e3 'a' "b\
+c" url( x ) u+1?? #\66oo /**/ 10E+0% 1.50px`
+	want := []string{`\31 23`, ` `, `1e3`, ` `, `'a'`, ` `, "\"b\\\nc\"", ` `, `url( x )`, ` `, `u+1??`, ` `, `#\66oo`, ` `, ` `, `10E+0%`, ` `, `1.50px`, ``}
+	tokenizer := NewTokenizer(input)
+	var r []string
+	for {
+		tk := tokenizer.NextToken()
+		r = append(r, tk.SourceText())
+		if tk.Type() == EOF {
+			break
+		}
+	}
+
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf(`Got source text %q, want %q`, r, want)
+	}
+}
+
+func TestSerialize(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/component_value_list.json")
+	if err != nil {
+		t.Fatal("Could not read component_value_list.json")
+	}
+
+	var arr []interface{}
+	if err := json.Unmarshal(data, &arr); err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := []string{
+		`a\ b \31 23 -\31 a --a 1e3 1\65 3 1\45+3 1\65 m #\31 a #-1 #--x #\0 u\+1 u+1?? u+1-2 u\+a`,
+		`url(a\)b\ c) url("x y'") url('') url(\0) "a\"b" 'a\'b' "\0" "a
+b" \
+x`,
+		`1/**/% a/**/b a/**/( -/**/-/**/> </**/!-- 1/**/.5 @/**/x @/**/-x ./**/5 +/**/5 #/**/a`,
+		`|/**/= |/**/| |/**/|= $/**/= */**/= ^/**/= ~/**/= //**/* //**/*= /**/ /**/ a/**/-->`,
+		`u/**/+1 u/**/+?? U+1/**/a U+1/**/? U+1/**/-2 1/**/- 2/**/-/**/3 1px/**/2 #a/**/1 @a/**/-`,
+	}
+
+	for i := 0; i < len(arr); i += 2 {
+		inputs = append(inputs, arr[i].(string))
+	}
+
+	for _, input := range inputs {
+		tokens := tokenizeAll(NewTokenizer(input))
+		s := Serialize(tokens)
+		if r, want := tokenTypesAndValues(tokenizeAll(NewTokenizer(s)), t), tokenTypesAndValues(tokens, t); !reflect.DeepEqual(r, want) {
+			t.Errorf(`Got tokens %v tokenizing %q serialized from %q, want %v`, r, s, input, want)
+		}
+	}
+}
+
+func TestSerializeComments(t *testing.T) {
+	tests := map[string]string{
+		`a b`:            `a b`,
+		`a/**/b`:         `a/**/b`,
+		`1/* % */%`:      `1/**/%`,
+		`1 /**/ %`:       `1 /**/ %`,
+		`a(b) a /**/(b)`: `a(b) a (b)`,
+		`a/**/(b)`:       `a/**/(b)`,
+		`.a/**/.5`:       `.a/**/.5`,
+		`a/**/ /**/ b`:   `a /**/ b`,
+		`--a`:            `-/**/-a`,
+		`\-\-a 1\65 3`:   `-\-a 1\65 3`,
+	}
+
+	for k, v := range tests {
+		if r := Serialize(tokenizeAll(NewTokenizer(k))); r != v {
+			t.Errorf(`Got %q serializing the tokens of %q, want %q`, r, k, v)
+		}
+	}
+}
+
+// Returns all tokens returned from the tokenizer, including the EOF token.
+func tokenizeAll(tokenizer Tokenizer) []Token {
+	var r []Token
+	for {
+		tk := tokenizer.NextToken()
+		r = append(r, tk)
+		if tk.Type() == EOF {
+			return r
+		}
+	}
+}
+
+// Returns the types and css-parsing-tests representations of the tokens.
+func tokenTypesAndValues(tokens []Token, t *testing.T) []interface{} {
+	var r []interface{}
+	for _, tk := range tokens {
+		r = append(r, vals(tk.Type(), tokenValue(tk, t)))
+	}
+
+	return r
+}
+
+func TestNumericValues(t *testing.T) {
+	tests := []struct {
+		input  string
+		float  float64
+		int    int64
+		signed bool
+	}{
+		{"0", 0, 0, false},
+		{"+12", 12, 12, true},
+		{"-12px", -12, -12, true},
+		{"1.5%", 1.5, 0, false},
+		{"-.5e-1", -0.05, 0, true},
+		{"1E3", 1000, 0, false},
+		{"9223372036854775807", 9223372036854775807, math.MaxInt64, false},
+		{"9223372036854775808em", 9223372036854775808, math.MaxInt64, false},
+		{"-99999999999999999999", -99999999999999999999, math.MinInt64, true},
+		{"1e400", math.MaxFloat64, 0, false},
+		{"-1e400", -math.MaxFloat64, 0, true},
+		{"1e-400", 0, 0, false},
+	}
+
+	for _, test := range tests {
+		var f float64
+		var n int64
+		var signed bool
+		switch tk := NewTokenizer(test.input).NextToken().(type) {
+		case *NumberToken:
+			f, n, signed = tk.Float, tk.Int, tk.Signed
+		case *DimensionToken:
+			f, n, signed = tk.Float, tk.Int, tk.Signed
+		default:
+			t.Errorf(`Got token %v tokenizing %q, want a numeric token`, tk, test.input)
+			continue
+		}
+
+		if f != test.float || n != test.int || signed != test.signed {
+			t.Errorf(`Got values %v, %v and %v tokenizing %q, want %v, %v and %v`, f, n, signed, test.input, test.float, test.int, test.signed)
+		}
+	}
 }
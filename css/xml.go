@@ -0,0 +1,208 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// XMLNodeType identifies the type of an XMLNode.
+type XMLNodeType int
+
+const (
+	XMLDocumentNode XMLNodeType = iota
+	XMLElementNode
+	XMLTextNode
+)
+
+// Represents a node of a tree built from XML using the encoding/xml package.
+// Comments, processing instructions and directives aren't kept in the tree.
+type XMLNode struct {
+	Parent, FirstChild, LastChild, PrevSibling, NextSibling *XMLNode
+
+	Type XMLNodeType
+	Name xml.Name   // The element name, with the namespace URL as space.
+	Attr []xml.Attr // The element attributes.
+	Data string     // The character data of text nodes.
+}
+
+// Appends c as the last child of n.
+func (n *XMLNode) AppendChild(c *XMLNode) {
+	c.Parent = n
+	c.PrevSibling = n.LastChild
+	if n.LastChild != nil {
+		n.LastChild.NextSibling = c
+	} else {
+		n.FirstChild = c
+	}
+
+	n.LastChild = c
+}
+
+// Parses XML from r and returns the document node of the resulting tree.
+func ParseXML(r io.Reader) (*XMLNode, error) {
+	return ParseXMLWithDecoder(xml.NewDecoder(r))
+}
+
+// Parses XML using the decoder d and returns the document node of the resulting tree.
+func ParseXMLWithDecoder(d *xml.Decoder) (*XMLNode, error) {
+	doc := &XMLNode{Type: XMLDocumentNode}
+	n := doc
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return doc, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch x := t.(type) {
+		case xml.StartElement:
+			c := &XMLNode{Type: XMLElementNode, Name: x.Name, Attr: x.Copy().Attr}
+			n.AppendChild(c)
+			n = c
+		case xml.EndElement:
+			n = n.Parent
+		case xml.CharData:
+			if n != doc {
+				n.AppendChild(&XMLNode{Type: XMLTextNode, Data: string(x)})
+			}
+		}
+	}
+}
+
+// Adapts an XML node of type XMLElementNode to the Element interface.
+type XMLElement struct {
+	Node *XMLNode
+}
+
+func (e XMLElement) LocalName() string {
+	return e.Node.Name.Local
+}
+
+func (e XMLElement) Namespace() string {
+	return e.Node.Name.Space
+}
+
+func (e XMLElement) IsHTML() bool {
+	return false
+}
+
+func (e XMLElement) NumAttributes() int {
+	return len(e.Node.Attr)
+}
+
+// The decoder leaves the xmlns prefix of namespace declarations as is,
+// and namespace declarations are in the XMLNSNamespace.
+func (e XMLElement) Attribute(i int) Attr {
+	a := &e.Node.Attr[i]
+	if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+		return Attr{XMLNSNamespace, a.Name.Local, a.Value}
+	}
+
+	return Attr{a.Name.Space, a.Name.Local, a.Value}
+}
+
+func (e XMLElement) Parent() Element {
+	return xmlElementOrNil(e.Node.Parent)
+}
+
+func (e XMLElement) PrevSibling() Element {
+	for x := e.Node.PrevSibling; x != nil; x = x.PrevSibling {
+		if x.Type == XMLElementNode {
+			return XMLElement{x}
+		}
+	}
+
+	return nil
+}
+
+func (e XMLElement) NextSibling() Element {
+	for x := e.Node.NextSibling; x != nil; x = x.NextSibling {
+		if x.Type == XMLElementNode {
+			return XMLElement{x}
+		}
+	}
+
+	return nil
+}
+
+func (e XMLElement) FirstChild() Element {
+	for x := e.Node.FirstChild; x != nil; x = x.NextSibling {
+		if x.Type == XMLElementNode {
+			return XMLElement{x}
+		}
+	}
+
+	return nil
+}
+
+func (e XMLElement) LastChild() Element {
+	for x := e.Node.LastChild; x != nil; x = x.PrevSibling {
+		if x.Type == XMLElementNode {
+			return XMLElement{x}
+		}
+	}
+
+	return nil
+}
+
+// Returns whether the element has no parent element, i.e. whether it's the root element
+// of a document or the root of a detached subtree.
+func (e XMLElement) IsRoot() bool {
+	return xmlElementOrNil(e.Node.Parent) == nil
+}
+
+// Returns whether the element only has whitespace text children.
+// See http://dev.w3.org/csswg/selectors-4/#the-empty-pseudo
+func (e XMLElement) IsEmpty() bool {
+	for c := e.Node.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == XMLElementNode || (c.Type == XMLTextNode && !isWhitespaceText(c.Data, xmlWhitespace)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the concatenated data of the text nodes within the element.
+func (e XMLElement) TextContent() string {
+	var b bytes.Buffer
+	var f func(*XMLNode)
+	f = func(n *XMLNode) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == XMLTextNode {
+				b.WriteString(c.Data)
+			} else {
+				f(c)
+			}
+		}
+	}
+
+	f(e.Node)
+	return b.String()
+}
+
+// Returns n as an Element if it's an element node, nil otherwise.
+func xmlElementOrNil(n *XMLNode) Element {
+	if n == nil || n.Type != XMLElementNode {
+		return nil
+	}
+
+	return XMLElement{n}
+}
+
+// Returns the root element of the XML document node n, nil if none.
+func XMLRootElement(n *XMLNode) Element {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == XMLElementNode {
+			return XMLElement{c}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2014 Christer Sandberg.
+// Use of this source code is governed by a Apache 2.0
+// license that can be found in the LICENSE file.
+
+package css
+
+import (
+	"strings"
+	"testing"
+)
+
+const testXML = `<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:x="urn:x">
+	<title type="TEXT">Feed</title>
+	<entry id="a" class="first item"><title>A</title><x:Link x:rel="alternate" href="/a"/></entry>
+	<entry id="b" class="item"><title>B</title><empty/></entry>
+	<x:entry><title></title></x:entry>
+</feed>`
+
+var testXMLSelectors = map[string]int{
+	`feed`:                     1,
+	`:root`:                    1,
+	`title`:                    4,
+	`TITLE`:                    0,
+	`atom|entry`:               2,
+	`x|entry`:                  1,
+	`*|entry`:                  3,
+	`x|link`:                   0,
+	`x|Link`:                   1,
+	`[x|rel]`:                  1,
+	`[rel]`:                    0,
+	`[href^="/"]`:              1,
+	`[type=text]`:              0,
+	`[type=text i]`:            1,
+	`#a, .item`:                2,
+	`entry#a.first`:            1,
+	`entry.first#b`:            0,
+	`Link`:                     1,
+	`link.item`:                0,
+	`entry:first-of-type`:      2,
+	`atom|entry:nth-child(3)`:  1,
+	`:empty`:                   3,
+	`entry:has(> empty)`:       1,
+	`entry + entry > title`:    2,
+	`feed > :not(title)`:       3,
+	`[xmlns|x]`:                1,
+	`atom|title:only-child`:    1,
+	`atom|title:first-child`:   4,
+	`:is(x|entry, #b) > title`: 2,
+}
+
+func TestXMLMatching(t *testing.T) {
+	doc, err := ParseXML(strings.NewReader(testXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := XMLRootElement(doc)
+	namespaces := map[string]string{
+		"atom":  "http://www.w3.org/2005/Atom",
+		"x":     "urn:x",
+		"xmlns": XMLNSNamespace,
+	}
+
+	for k, v := range testXMLSelectors {
+		s, err := ParseSelectorWithNamespaces(NewTokenizer(k), namespaces)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+			continue
+		}
+
+		if r := QueryAllElements(s, root); len(r) != v {
+			t.Errorf(`Got %v elements matching %q, want %v`, len(r), k, v)
+		}
+
+		m, err := Compile(s)
+		if err != nil {
+			t.Errorf(`Could not compile selector %q (%s)`, k, err)
+		} else if r := m.QueryAllElements(root); len(r) != v {
+			t.Errorf(`Got %v elements matching compiled %q, want %v`, len(r), k, v)
+		}
+
+		set := NewElementSelectorSet(nil)
+		set.Add(s, nil)
+		c := 0
+		TraverseElements(root, func(e Element) {
+			if len(set.MatchElement(e)) > 0 {
+				c++
+			}
+		})
+
+		if c != v {
+			t.Errorf(`Got %v elements matching %q in selector set, want %v`, c, k, v)
+		}
+	}
+}
+
+func TestHTMLElementQuery(t *testing.T) {
+	for k, v := range testSelectors {
+		s, err := ParseSelectorFromString(k)
+		if err != nil {
+			t.Errorf(`Could not parse selector %q (%s)`, k, err)
+		} else if r := QueryAllElements(s, htmlElement(dom)); len(r) != v {
+			t.Errorf(`Got %v elements matching %q, want %v`, len(r), k, v)
+		}
+	}
+}